@@ -2,4 +2,4 @@ module memodb
 
 go 1.19
 
-require github.com/google/uuid v1.6.0 // indirect
+require github.com/google/uuid v1.6.0
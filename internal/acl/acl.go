@@ -0,0 +1,224 @@
+package acl
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// DefaultUsername is the name of the user every connection starts as, it is the user AUTH <password> logs in.
+const DefaultUsername = "default"
+
+// categories holds the ACL categories commands are sorted in, in the order ACL CAT reports them. The all category
+// holds every command and is only used in rules.
+var categories = []string{
+	"keyspace", "read", "write", "set", "sortedset", "list", "hash", "string", "bitmap", "hyperloglog", "geo", "stream",
+	"pubsub", "admin", "fast", "slow", "blocking", "dangerous", "connection", "transaction", "scripting",
+}
+
+var (
+	// mutex guards users
+	mutex sync.RWMutex
+	users = map[string]*User{DefaultUsername: newDefaultUser()}
+	// commandExists checks if a command (or one of its subcommands) exists, set by SetCommandLookup
+	commandExists = func(command, subcommand string) bool { return true }
+)
+
+// SetCommandLookup sets the function rules use to check that the commands they name exist, subcommand is empty when a
+// rule names a command.
+func SetCommandLookup(lookup func(command, subcommand string) bool) {
+	commandExists = lookup
+}
+
+// Categories returns the names of the ACL categories.
+func Categories() []string {
+	return append([]string{}, categories...)
+}
+
+// IsCategory checks if name, without its '@', is an ACL category.
+func IsCategory(name string) bool {
+	if name == "all" {
+		return true
+	}
+	for _, category := range categories {
+		if category == name {
+			return true
+		}
+	}
+	return false
+}
+
+// Lookup returns the user with the given (case sensitive) name, or nil if there is no such user.
+func Lookup(name string) *User {
+	mutex.RLock()
+	defer mutex.RUnlock()
+	return users[name]
+}
+
+// DefaultUser returns the default user.
+func DefaultUser() *User {
+	return Lookup(DefaultUsername)
+}
+
+// Users returns every user sorted by name.
+func Users() []*User {
+	mutex.RLock()
+	defer mutex.RUnlock()
+	sorted := make([]*User, 0, len(users))
+	for _, user := range users {
+		sorted = append(sorted, user)
+	}
+	sort.Slice(sorted, func(i, j int) bool {
+		return sorted[i].Name < sorted[j].Name
+	})
+	return sorted
+}
+
+// SetUser applies rules to a user, creating it when it does not exist. Rules are applied to a copy of the user which
+// replaces it once they all succeeded, so the user is left unchanged when one of them is invalid.
+func SetUser(name string, rules ...string) error {
+	if strings.ContainsAny(name, " \t\r\n") {
+		return fmt.Errorf("ERR Usernames can't contain spaces or null characters")
+	}
+	mutex.Lock()
+	defer mutex.Unlock()
+	user := newUser(name)
+	if existing, isPresent := users[name]; isPresent {
+		user = existing.clone()
+	}
+	for _, rule := range rules {
+		if err := user.applyRule(rule); err != nil {
+			return fmt.Errorf("ERR Error in ACL SETUSER modifier '%s': %s", rule, err.Error())
+		}
+	}
+	users[name] = user
+	return nil
+}
+
+// DeleteUser removes a user and reports whether it existed. The default user can not be removed.
+func DeleteUser(name string) (bool, error) {
+	if name == DefaultUsername {
+		return false, fmt.Errorf("ERR The 'default' user cannot be removed")
+	}
+	mutex.Lock()
+	defer mutex.Unlock()
+	_, isPresent := users[name]
+	delete(users, name)
+	return isPresent, nil
+}
+
+// SetDefaultPassword makes password the only password of the default user, as done by requirepass. An empty password
+// lets the default user in without password.
+func SetDefaultPassword(password string) {
+	rule := "nopass"
+	if password != "" {
+		rule = ">" + password
+	}
+	// both rules are always valid
+	SetUser(DefaultUsername, "resetpass", rule)
+}
+
+// Authenticate checks the credentials of a user, it returns the user when they are valid and it is enabled, else nil.
+func Authenticate(name, password string) *User {
+	user := Lookup(name)
+	if user == nil || !user.Enabled || !user.checkPassword(password) {
+		return nil
+	}
+	return user
+}
+
+/*
+	LoadFile replaces every user with those of the ACL file at path, as done by ACL LOAD and when the server starts.
+	Every line of the file describes a user in the format of ACL LIST, "user <name> <rule> ...", blank lines and lines
+	starting with '#' are skipped. Users are only replaced when the whole file is valid, the default user gets its
+	initial rules back when the file does not describe it.
+
+	Function Signature:
+		func LoadFile(path string) error
+
+	Parameters:
+		- path: Path of the ACL file. (string)
+
+	Returns:
+		- error - Error telling the line at fault, if any, else nil.
+
+	Example Usage:
+		// users.acl holds "user alice on >secret ~cache:* +@read\n"
+		err := LoadFile("users.acl")
+		// Output err = nil, Lookup("alice").KeyRules() = "~cache:*", Lookup("default").NoPass = true
+*/
+func LoadFile(path string) error {
+	aclFile, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("error in reading ACL file %s: %s", path, err.Error())
+	}
+	defer aclFile.Close()
+
+	loaded := map[string]*User{}
+	scanner := bufio.NewScanner(aclFile)
+	lineNumber := 0
+	for scanner.Scan() {
+		lineNumber++
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 0 || strings.HasPrefix(fields[0], "#") {
+			continue
+		}
+		if fields[0] != "user" || len(fields) < 2 {
+			return fmt.Errorf("error in ACL file %s at line %d: should start with user <username>", path, lineNumber)
+		}
+		if _, isPresent := loaded[fields[1]]; isPresent {
+			return fmt.Errorf("error in ACL file %s at line %d: duplicate user '%s'", path, lineNumber, fields[1])
+		}
+		user := newUser(fields[1])
+		for _, rule := range fields[2:] {
+			if err := user.applyRule(rule); err != nil {
+				return fmt.Errorf("error in ACL file %s at line %d, rule '%s': %s", path, lineNumber, rule, err.Error())
+			}
+		}
+		loaded[user.Name] = user
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("error in reading ACL file %s: %s", path, err.Error())
+	}
+	if _, isPresent := loaded[DefaultUsername]; !isPresent {
+		loaded[DefaultUsername] = newDefaultUser()
+	}
+
+	mutex.Lock()
+	defer mutex.Unlock()
+	users = loaded
+	return nil
+}
+
+// SaveFile writes every user to the ACL file at path, as done by ACL SAVE. The new file replaces the old one once it
+// is synced to disk.
+func SaveFile(path string) error {
+	var content strings.Builder
+	for _, user := range Users() {
+		content.WriteString(user.Describe())
+		content.WriteByte('\n')
+	}
+
+	tempPath := fmt.Sprintf("%s.tmp-%d", path, os.Getpid())
+	tempFile, err := os.OpenFile(tempPath, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
+	if err != nil {
+		return err
+	}
+	_, err = tempFile.WriteString(content.String())
+	if err == nil {
+		err = tempFile.Sync()
+	}
+	if closeErr := tempFile.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(tempPath, path)
+	}
+	if err != nil {
+		os.Remove(tempPath)
+	}
+	return err
+}
@@ -0,0 +1,101 @@
+package acl
+
+import (
+	"sync"
+	"time"
+)
+
+// logEntryMaxAge is how long, in milliseconds, a log entry keeps counting the denials that are the same as its own.
+const logEntryMaxAge = 60 * 1000
+
+// LogEntry records commands denied by ACL rules and failed authentications, as reported by ACL LOG.
+type LogEntry struct {
+	// Count is the number of times the same denial happened within a minute of the previous one.
+	Count int
+	// Reason is command, key, channel or auth.
+	Reason string
+	Context string
+	// Object is the denied command, key or channel, or AUTH.
+	Object string
+	Username string
+	ClientInfo string
+	EntryId int64
+	// CreatedAt and UpdatedAt are unix times in milliseconds.
+	CreatedAt int64
+	UpdatedAt int64
+}
+
+var (
+	// logMutex guards logEntries, logMaxLen and lastEntryId
+	logMutex sync.Mutex
+	// logEntries holds the log entries, the most recent one first
+	logEntries []*LogEntry
+	logMaxLen = 128
+	lastEntryId int64
+)
+
+// AddLogEntry records a denial. When the same denial was recorded less than a minute ago the existing entry counts it
+// and moves to the top of the log.
+func AddLogEntry(reason, object, username, clientInfo string) {
+	logMutex.Lock()
+	defer logMutex.Unlock()
+	now := time.Now().UnixMilli()
+	for idx, entry := range logEntries {
+		if entry.Reason == reason && entry.Object == object && entry.Username == username && now - entry.UpdatedAt < logEntryMaxAge {
+			entry.Count++
+			entry.ClientInfo = clientInfo
+			entry.UpdatedAt = now
+			copy(logEntries[1:idx + 1], logEntries[:idx])
+			logEntries[0] = entry
+			return
+		}
+	}
+
+	entry := &LogEntry{
+		Count: 1,
+		Reason: reason,
+		Context: "toplevel",
+		Object: object,
+		Username: username,
+		ClientInfo: clientInfo,
+		EntryId: lastEntryId,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+	lastEntryId++
+	logEntries = append([]*LogEntry{entry}, logEntries...)
+	trimLog()
+}
+
+// LogEntries returns up to count log entries, the most recent one first.
+func LogEntries(count int) []LogEntry {
+	logMutex.Lock()
+	defer logMutex.Unlock()
+	entries := []LogEntry{}
+	for idx := 0; idx < len(logEntries) && idx < count; idx++ {
+		entries = append(entries, *logEntries[idx])
+	}
+	return entries
+}
+
+// ResetLog removes every log entry.
+func ResetLog() {
+	logMutex.Lock()
+	defer logMutex.Unlock()
+	logEntries = nil
+}
+
+// SetLogMaxLen sets the number of entries the log keeps, as done by acllog-max-len, older entries are dropped.
+func SetLogMaxLen(maxLen int) {
+	logMutex.Lock()
+	defer logMutex.Unlock()
+	logMaxLen = maxLen
+	trimLog()
+}
+
+// trimLog drops the entries beyond logMaxLen, logMutex must be held.
+func trimLog() {
+	if len(logEntries) > logMaxLen {
+		logEntries = logEntries[:logMaxLen]
+	}
+}
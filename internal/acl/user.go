@@ -0,0 +1,431 @@
+package acl
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"fmt"
+	"sort"
+	"strings"
+
+	"memodb/internal/glob"
+)
+
+// User is an ACL user, the commands it may run and the keys and channels they may access. Users are never modified
+// once registered, SetUser registers a modified copy, so a user returned by Lookup can be read without locking.
+type User struct {
+	Name string
+	// Enabled is cleared by the off rule, disabled users can not authenticate.
+	Enabled bool
+	// NoPass is set by the nopass rule, the user then accepts any password.
+	NoPass bool
+	// passwords holds the SHA-256 hashes of the passwords of the user in hex, sorted
+	passwords []string
+	// commandRules holds the command rules in the order they were applied, the last rule matching a command wins
+	commandRules []commandRule
+	keyPatterns []keyPattern
+	channelPatterns []string
+}
+
+// commandRule allows or denies every command (category "all"), the commands of a category, a command along with its
+// subcommands, or a single subcommand named container|subcommand.
+type commandRule struct {
+	allow bool
+	category string
+	command string
+}
+
+// keyPattern is a glob pattern of the keys a user may read, write or both.
+type keyPattern struct {
+	pattern string
+	read bool
+	write bool
+}
+
+// newUser returns a user in its initial state, disabled, without passwords and denied every command, key and channel.
+func newUser(name string) *User {
+	return &User{
+		Name: name,
+		commandRules: []commandRule{{allow: false, category: "all"}},
+	}
+}
+
+// newDefaultUser returns the default user as it is until it is configured, enabled without password and allowed every
+// command, key and channel.
+func newDefaultUser() *User {
+	return &User{
+		Name: DefaultUsername,
+		Enabled: true,
+		NoPass: true,
+		commandRules: []commandRule{{allow: true, category: "all"}},
+		keyPatterns: []keyPattern{{pattern: "*", read: true, write: true}},
+		channelPatterns: []string{"*"},
+	}
+}
+
+// clone returns a copy of the user that rules can be applied to without changing the user.
+func (u *User) clone() *User {
+	copied := *u
+	copied.passwords = append([]string{}, u.passwords...)
+	copied.commandRules = append([]commandRule{}, u.commandRules...)
+	copied.keyPatterns = append([]keyPattern{}, u.keyPatterns...)
+	copied.channelPatterns = append([]string{}, u.channelPatterns...)
+	return &copied
+}
+
+/*
+	applyRule applies an ACL rule, as given to ACL SETUSER, to the user. The rules understood are
+		on, off                          enable or disable the user
+		nopass, resetpass                accept any password, or forget every password
+		>password, <password             add or remove a password
+		#hash, !hash                     add or remove the SHA-256 hash of a password
+		~pattern, %R~pattern, %W~pattern allow reading and writing, reading or writing the keys matching the pattern
+		allkeys, resetkeys               allow every key (~*), or forget every key pattern
+		&pattern                         allow the pub/sub channels matching the pattern
+		allchannels, resetchannels       allow every channel (&*), or forget every channel pattern
+		+command, -command               allow or deny a command along with its subcommands
+		+command|subcommand, -...        allow or deny a single subcommand
+		+@category, -@category           allow or deny the commands of a category
+		allcommands, nocommands          same as +@all and -@all
+		reset                            go back to the state of a new user
+
+	Function Signature:
+		func (u *User) applyRule(rule string) error
+
+	Parameters:
+		- rule: The rule. (string)
+
+	Returns:
+		- error - Error telling why the rule is invalid, if any, else nil.
+
+	Example Usage:
+		user := newUser("alice")
+		err := user.applyRule("+@read")
+		// Output err = nil, user.CanRunCommand("get", "", []string{"@read", "@string", "@fast"}) = true
+		err := user.applyRule("+nosuchcommand")
+		// Output err = "Unknown command or category name in ACL"
+*/
+func (u *User) applyRule(rule string) error {
+	switch lowerRule := strings.ToLower(rule); {
+		case lowerRule == "on": {
+			u.Enabled = true
+		}
+		case lowerRule == "off": {
+			u.Enabled = false
+		}
+		case lowerRule == "nopass": {
+			u.NoPass = true
+			u.passwords = nil
+		}
+		case lowerRule == "resetpass": {
+			u.NoPass = false
+			u.passwords = nil
+		}
+		case lowerRule == "allkeys": {
+			u.keyPatterns = []keyPattern{{pattern: "*", read: true, write: true}}
+		}
+		case lowerRule == "resetkeys": {
+			u.keyPatterns = nil
+		}
+		case lowerRule == "allchannels": {
+			u.channelPatterns = []string{"*"}
+		}
+		case lowerRule == "resetchannels": {
+			u.channelPatterns = nil
+		}
+		case lowerRule == "allcommands": {
+			return u.applyRule("+@all")
+		}
+		case lowerRule == "nocommands": {
+			return u.applyRule("-@all")
+		}
+		case lowerRule == "reset": {
+			*u = *newUser(u.Name)
+		}
+		case lowerRule == "sanitize-payload" || lowerRule == "skip-sanitize-payload": {
+			// accepted for compatibility, there is no payload to sanitize
+		}
+		case strings.HasPrefix(rule, ">"): {
+			u.addPassword(hashPassword(rule[1:]))
+		}
+		case strings.HasPrefix(rule, "<"): {
+			return u.removePassword(hashPassword(rule[1:]))
+		}
+		case strings.HasPrefix(rule, "#"): {
+			if !isPasswordHash(rule[1:]) {
+				return fmt.Errorf("The password hash must be exactly 64 characters and contain only lowercase hexadecimal characters")
+			}
+			u.addPassword(rule[1:])
+		}
+		case strings.HasPrefix(rule, "!"): {
+			if !isPasswordHash(rule[1:]) {
+				return fmt.Errorf("The password hash must be exactly 64 characters and contain only lowercase hexadecimal characters")
+			}
+			return u.removePassword(rule[1:])
+		}
+		case strings.HasPrefix(rule, "~") || strings.HasPrefix(rule, "%"): {
+			return u.addKeyPattern(rule)
+		}
+		case strings.HasPrefix(rule, "&"): {
+			u.addChannelPattern(rule[1:])
+		}
+		case strings.HasPrefix(rule, "+") || strings.HasPrefix(rule, "-"): {
+			return u.addCommandRule(rule[0] == '+', strings.ToLower(rule[1:]))
+		}
+		default: {
+			return fmt.Errorf("Syntax error")
+		}
+	}
+	return nil
+}
+
+// addPassword adds the hash of a password to the passwords of the user.
+func (u *User) addPassword(hash string) {
+	u.NoPass = false
+	for _, password := range u.passwords {
+		if password == hash {
+			return
+		}
+	}
+	u.passwords = append(u.passwords, hash)
+	sort.Strings(u.passwords)
+}
+
+// removePassword removes the hash of a password from the passwords of the user.
+func (u *User) removePassword(hash string) error {
+	for idx, password := range u.passwords {
+		if password == hash {
+			u.passwords = append(u.passwords[:idx], u.passwords[idx + 1:]...)
+			return nil
+		}
+	}
+	return fmt.Errorf("The password you are trying to remove from the user does not exist")
+}
+
+// addKeyPattern adds the pattern of a ~pattern, %R~pattern, %W~pattern or %RW~pattern rule to the key patterns.
+func (u *User) addKeyPattern(rule string) error {
+	pattern := keyPattern{read: true, write: true}
+	if strings.HasPrefix(rule, "%") {
+		permissions, rest, found := strings.Cut(rule[1:], "~")
+		pattern.read = strings.ContainsAny(permissions, "Rr")
+		pattern.write = strings.ContainsAny(permissions, "Ww")
+		if !found || permissions == "" || strings.Trim(permissions, "RWrw") != "" {
+			return fmt.Errorf("Syntax error")
+		}
+		rule = "~" + rest
+	}
+	pattern.pattern = rule[1:]
+
+	for idx, existing := range u.keyPatterns {
+		if existing.pattern == pattern.pattern {
+			u.keyPatterns[idx].read = existing.read || pattern.read
+			u.keyPatterns[idx].write = existing.write || pattern.write
+			return nil
+		}
+	}
+	u.keyPatterns = append(u.keyPatterns, pattern)
+	return nil
+}
+
+// addChannelPattern adds a pattern to the channel patterns.
+func (u *User) addChannelPattern(pattern string) {
+	for _, existing := range u.channelPatterns {
+		if existing == pattern {
+			return
+		}
+	}
+	u.channelPatterns = append(u.channelPatterns, pattern)
+}
+
+// addCommandRule adds a rule allowing or denying a category (@name), a command or a subcommand (container|name).
+func (u *User) addCommandRule(allow bool, name string) error {
+	rule := commandRule{allow: allow}
+	if strings.HasPrefix(name, "@") {
+		rule.category = name[1:]
+		if !IsCategory(rule.category) {
+			return fmt.Errorf("Unknown command or category name in ACL")
+		}
+	} else {
+		container, subcommand, isSubcommand := strings.Cut(name, "|")
+		if !commandExists(container, "") || (isSubcommand && (subcommand == "" || !commandExists(container, subcommand))) {
+			return fmt.Errorf("Unknown command or category name in ACL")
+		}
+		rule.command = name
+	}
+
+	if rule.category == "all" {
+		// +@all and -@all override every earlier rule
+		u.commandRules = []commandRule{rule}
+		return nil
+	}
+	// an earlier rule about the same commands no longer has any effect
+	rules := u.commandRules[:0]
+	for _, existing := range u.commandRules {
+		if existing.category != rule.category || existing.command != rule.command {
+			rules = append(rules, existing)
+		}
+	}
+	u.commandRules = append(rules, rule)
+	return nil
+}
+
+// CanRunCommand checks if the user may run a command, subcommand is empty for commands without subcommands and
+// categories are those of the command (or subcommand), each one starting with '@'.
+func (u *User) CanRunCommand(command, subcommand string, categories []string) bool {
+	allowed := false
+	for _, rule := range u.commandRules {
+		if rule.matches(command, subcommand, categories) {
+			allowed = rule.allow
+		}
+	}
+	return allowed
+}
+
+// matches checks if the rule is about a command.
+func (rule commandRule) matches(command, subcommand string, categories []string) bool {
+	if rule.category == "all" {
+		return true
+	}
+	if rule.category != "" {
+		for _, category := range categories {
+			if category == "@" + rule.category {
+				return true
+			}
+		}
+		return false
+	}
+	return rule.command == command || (subcommand != "" && rule.command == command + "|" + subcommand)
+}
+
+// CanAccessKey checks if the user may access a key, for writing when write is set, else for reading.
+func (u *User) CanAccessKey(key string, write bool) bool {
+	for _, pattern := range u.keyPatterns {
+		if ((write && pattern.write) || (!write && pattern.read)) && glob.Match(pattern.pattern, key, false) {
+			return true
+		}
+	}
+	return false
+}
+
+// CanAccessChannel checks if the user may publish or subscribe to a pub/sub channel.
+func (u *User) CanAccessChannel(channel string) bool {
+	for _, pattern := range u.channelPatterns {
+		if glob.Match(pattern, channel, false) {
+			return true
+		}
+	}
+	return false
+}
+
+// checkPassword checks if password is one of the passwords of the user.
+func (u *User) checkPassword(password string) bool {
+	if u.NoPass {
+		return true
+	}
+	hash := hashPassword(password)
+	matches := false
+	for _, stored := range u.passwords {
+		// every hash is compared so the time taken does not tell which password matched
+		matches = subtle.ConstantTimeCompare([]byte(stored), []byte(hash)) == 1 || matches
+	}
+	return matches
+}
+
+// Flags returns the flags of the user as reported by ACL GETUSER.
+func (u *User) Flags() []string {
+	flags := []string{"off"}
+	if u.Enabled {
+		flags[0] = "on"
+	}
+	if u.NoPass {
+		flags = append(flags, "nopass")
+	}
+	return flags
+}
+
+// Passwords returns the SHA-256 hashes of the passwords of the user.
+func (u *User) Passwords() []string {
+	return append([]string{}, u.passwords...)
+}
+
+// CommandRules describes the command rules of the user, such as "+@all -config".
+func (u *User) CommandRules() string {
+	rules := make([]string, 0, len(u.commandRules))
+	for _, rule := range u.commandRules {
+		sign := "-"
+		if rule.allow {
+			sign = "+"
+		}
+		if rule.category != "" {
+			rules = append(rules, sign + "@" + rule.category)
+		} else {
+			rules = append(rules, sign + rule.command)
+		}
+	}
+	return strings.Join(rules, " ")
+}
+
+// KeyRules describes the key patterns of the user, such as "~cache:* %R~config:*".
+func (u *User) KeyRules() string {
+	rules := make([]string, 0, len(u.keyPatterns))
+	for _, pattern := range u.keyPatterns {
+		switch {
+			case pattern.read && pattern.write: {
+				rules = append(rules, "~" + pattern.pattern)
+			}
+			case pattern.read: {
+				rules = append(rules, "%R~" + pattern.pattern)
+			}
+			default: {
+				rules = append(rules, "%W~" + pattern.pattern)
+			}
+		}
+	}
+	return strings.Join(rules, " ")
+}
+
+// ChannelRules describes the channel patterns of the user, such as "&news:*".
+func (u *User) ChannelRules() string {
+	rules := make([]string, 0, len(u.channelPatterns))
+	for _, pattern := range u.channelPatterns {
+		rules = append(rules, "&" + pattern)
+	}
+	return strings.Join(rules, " ")
+}
+
+// Describe returns the rules that rebuild the user from a new one, in the format of ACL LIST and the ACL file.
+func (u *User) Describe() string {
+	rules := []string{"user", u.Name}
+	rules = append(rules, u.Flags()...)
+	for _, password := range u.passwords {
+		rules = append(rules, "#" + password)
+	}
+	if keys := u.KeyRules(); keys != "" {
+		rules = append(rules, keys)
+	}
+	if channels := u.ChannelRules(); channels != "" {
+		rules = append(rules, channels)
+	} else {
+		rules = append(rules, "resetchannels")
+	}
+	return strings.Join(append(rules, u.CommandRules()), " ")
+}
+
+// hashPassword returns the SHA-256 hash of a password in hex.
+func hashPassword(password string) string {
+	hash := sha256.Sum256([]byte(password))
+	return hex.EncodeToString(hash[:])
+}
+
+// isPasswordHash checks if hash is a SHA-256 hash in lower case hex.
+func isPasswordHash(hash string) bool {
+	if len(hash) != sha256.Size * 2 {
+		return false
+	}
+	for idx := 0; idx < len(hash); idx++ {
+		if !(hash[idx] >= '0' && hash[idx] <= '9') && !(hash[idx] >= 'a' && hash[idx] <= 'f') {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,359 @@
+package aof
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"memodb/internal/resp"
+	"memodb/internal/store/rdb"
+)
+
+// fsync policies of the append only file
+const (
+	FsyncAlways = "always"
+	FsyncEverySec = "everysec"
+	FsyncNo = "no"
+)
+
+var (
+	// mutex guards the state of the append only file below
+	mutex sync.Mutex
+	file *os.File
+	enabled bool
+	fsyncPolicy = FsyncEverySec
+	// pendingFsync is set when data was written since the last fsync
+	pendingFsync bool
+	// rewriteBuffer accumulates the commands appended while a rewrite is in progress, nil when there is none
+	rewriteBuffer []byte
+	// selectedDb is the database selected at the end of the file, -1 when the next command has to select it
+	selectedDb = -1
+	lastWriteOk = true
+
+	rewriteInProgress int32
+	lastRewriteOk int32 = 1
+	fsyncLoopOnce sync.Once
+)
+
+// IsValidFsyncPolicy checks if policy is one of always, everysec or no.
+func IsValidFsyncPolicy(policy string) bool {
+	return policy == FsyncAlways || policy == FsyncEverySec || policy == FsyncNo
+}
+
+// SetFsyncPolicy changes when the append only file is synced to disk: after every write (always), once per second
+// (everysec) or whenever the operating system decides to (no).
+func SetFsyncPolicy(policy string) error {
+	if !IsValidFsyncPolicy(policy) {
+		return fmt.Errorf("invalid appendfsync policy '%s', expected always, everysec or no", policy)
+	}
+	mutex.Lock()
+	defer mutex.Unlock()
+	fsyncPolicy = policy
+	return nil
+}
+
+// Enabled checks if write commands are being appended to the append only file.
+func Enabled() bool {
+	mutex.Lock()
+	defer mutex.Unlock()
+	return enabled
+}
+
+// Open starts appending write commands to the existing append only file at dirPath/fileName, creating it if needed.
+// It is used at startup, once the file has been loaded.
+func Open(dirPath, fileName string) error {
+	mutex.Lock()
+	defer mutex.Unlock()
+
+	appendFile, err := os.OpenFile(aofFilePath(dirPath, fileName), os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0644)
+	if err != nil {
+		return fmt.Errorf("error in opening append only file: %s", err.Error())
+	}
+	file = appendFile
+	enabled = true
+	selectedDb = -1
+	startFsyncLoop()
+	return nil
+}
+
+// Start creates the append only file at dirPath/fileName out of the current dataset and starts appending write
+// commands to it. It is used when the append only file is turned on while the server is running. The caller holds off
+// write commands until Start returns, so every write is either part of the snapshot or appended after it.
+func Start(dirPath, fileName string, snapshot func() []rdb.RDBDatabase) error {
+	mutex.Lock()
+	defer mutex.Unlock()
+	if enabled {
+		return nil
+	}
+
+	appendFile, err := writeRewrittenFile(aofFilePath(dirPath, fileName), snapshot())
+	if err != nil {
+		return err
+	}
+	file = appendFile
+	enabled = true
+	selectedDb = -1
+	startFsyncLoop()
+	return nil
+}
+
+// Stop flushes and closes the append only file, write commands are no longer appended.
+func Stop() error {
+	mutex.Lock()
+	defer mutex.Unlock()
+	if !enabled {
+		return nil
+	}
+
+	enabled = false
+	err := file.Sync()
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	file = nil
+	pendingFsync = false
+	return err
+}
+
+// Fsync syncs the append only file to disk whatever the fsync policy, as done before shutting down.
+func Fsync() error {
+	mutex.Lock()
+	defer mutex.Unlock()
+	if !enabled {
+		return nil
+	}
+	if err := file.Sync(); err != nil {
+		return err
+	}
+	pendingFsync = false
+	return nil
+}
+
+// Append writes a command executed in database db, serialized as RESP, at the end of the append only file. The command
+// is preceded by a SELECT when the file is on another database.
+func Append(db int, command []byte) {
+	mutex.Lock()
+	defer mutex.Unlock()
+	if !enabled {
+		return
+	}
+
+	if db != selectedDb {
+		selectCommand, err := resp.SerializeResp(resp.NewBulkStringArray("SELECT", strconv.Itoa(db)))
+		if err != nil {
+			// without the SELECT the command would be replayed in the database of the previous one
+			lastWriteOk = false
+			fmt.Printf("Error serializing the SELECT of the append only file: %s\n", err.Error())
+			return
+		}
+		command = append([]byte(selectCommand), command...)
+	}
+
+	if rewriteBuffer != nil {
+		rewriteBuffer = append(rewriteBuffer, command...)
+	}
+
+	if _, err := file.Write(command); err != nil {
+		lastWriteOk = false
+		// the SELECT may not have been written, the next command writes it again
+		selectedDb = -1
+		fmt.Printf("Error writing to the append only file: %s\n", err.Error())
+		return
+	}
+	lastWriteOk = true
+	selectedDb = db
+
+	switch fsyncPolicy {
+		case FsyncAlways: {
+			if err := file.Sync(); err != nil {
+				lastWriteOk = false
+				fmt.Printf("Error syncing the append only file: %s\n", err.Error())
+			}
+		}
+		case FsyncEverySec: {
+			pendingFsync = true
+		}
+	}
+}
+
+// startFsyncLoop starts syncing the append only file once per second for the everysec policy, mutex must be held.
+func startFsyncLoop() {
+	fsyncLoopOnce.Do(func() {
+		go func() {
+			for range time.Tick(time.Second) {
+				mutex.Lock()
+				if enabled && pendingFsync && fsyncPolicy == FsyncEverySec {
+					if err := file.Sync(); err != nil {
+						fmt.Printf("Error syncing the append only file: %s\n", err.Error())
+					}
+					pendingFsync = false
+				}
+				mutex.Unlock()
+			}
+		}()
+	})
+}
+
+// Load replays the append only file at dirPath/fileName by passing every command it contains to execute, and returns
+// the number of commands replayed. A file starting with an RDB preamble, as written by a rewrite, has the keys of the
+// preamble passed to loadKey first. A missing file is not an error. When the last command was only partially written,
+// like after a crash, the file is truncated right before it and loading succeeds.
+func Load(dirPath, fileName string, loadKey rdb.KeyHandler, execute func(command *resp.RespType) error) (int, error) {
+	path := aofFilePath(dirPath, fileName)
+	appendFile, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, fmt.Errorf("error in opening append only file: %s", err.Error())
+	}
+	defer appendFile.Close()
+
+	counter := &countingReader{rd: appendFile}
+	// the preamble and the commands share one buffer, so the decoder leaves the commands following the preamble to it
+	bufferedReader := bufio.NewReaderSize(counter, 16 * 1024)
+	if header, _ := bufferedReader.Peek(len(rdb.RDBHeader)); string(header) == string(rdb.RDBHeader) {
+		if _, err := rdb.DecodeRdb(bufferedReader, loadKey); err != nil {
+			return 0, fmt.Errorf("error in loading the rdb preamble of the append only file: %s", err.Error())
+		}
+	}
+
+	respReader := resp.NewReader(bufferedReader)
+	count := 0
+	for {
+		validOffset := counter.count - int64(respReader.Buffered())
+		command, err := respReader.ReadCommand()
+		if err == io.EOF {
+			return count, nil
+		}
+		if err == io.ErrUnexpectedEOF {
+			fmt.Printf("The append only file is truncated, discarding the last %d bytes\n", counter.count - validOffset)
+			if err := os.Truncate(path, validOffset); err != nil {
+				return count, fmt.Errorf("error in truncating append only file: %s", err.Error())
+			}
+			return count, nil
+		}
+		if err != nil {
+			return count, fmt.Errorf("bad file format reading the append only file at offset %d: %s", validOffset, err.Error())
+		}
+
+		if err := execute(command); err != nil {
+			return count, fmt.Errorf("error replaying command %d of the append only file: %s", count + 1, err.Error())
+		}
+		count++
+	}
+}
+
+// BackgroundRewrite rewrites the append only file at dirPath/fileName in the background, with the shortest sequence of
+// commands rebuilding the current dataset. Commands appended during the rewrite are added to the new file before it
+// replaces the old one. The caller holds off write commands until BackgroundRewrite returns, so every write is either
+// part of the snapshot or buffered, never both.
+func BackgroundRewrite(dirPath, fileName string, snapshot func() []rdb.RDBDatabase) error {
+	if !atomic.CompareAndSwapInt32(&rewriteInProgress, 0, 1) {
+		return fmt.Errorf("ERR Background append only file rewriting already in progress")
+	}
+
+	mutex.Lock()
+	rewriteBuffer = []byte{}
+	// the rewritten file starts on database 0, so the first buffered command has to select its database
+	selectedDb = -1
+	mutex.Unlock()
+	databases := snapshot()
+
+	go func() {
+		defer atomic.StoreInt32(&rewriteInProgress, 0)
+
+		path := aofFilePath(dirPath, fileName)
+		tempPath := fmt.Sprintf("%s.temp-rewrite-%d", path, os.Getpid())
+		// the snapshot is written without holding the lock, only the commands buffered meanwhile are written with it
+		tempFile, err := writeRewrittenFile(tempPath, databases)
+
+		mutex.Lock()
+		defer mutex.Unlock()
+		if err == nil {
+			_, err = tempFile.Write(rewriteBuffer)
+			if err == nil {
+				err = tempFile.Sync()
+			}
+			if err == nil {
+				err = os.Rename(tempPath, path)
+			}
+			if err != nil {
+				tempFile.Close()
+			}
+		}
+		rewriteBuffer = nil
+
+		if err != nil {
+			os.Remove(tempPath)
+			atomic.StoreInt32(&lastRewriteOk, 0)
+			fmt.Printf("Background append only file rewriting error: %s\n", err.Error())
+			return
+		}
+		atomic.StoreInt32(&lastRewriteOk, 1)
+		if enabled {
+			file.Close()
+			file = tempFile
+			pendingFsync = false
+		} else {
+			tempFile.Close()
+		}
+		fmt.Println("Background append only file rewriting terminated with success")
+	}()
+	return nil
+}
+
+// Status returns whether a rewrite is in progress and whether the last rewrite and the last write succeeded.
+func Status() (bool, bool, bool) {
+	mutex.Lock()
+	defer mutex.Unlock()
+	return atomic.LoadInt32(&rewriteInProgress) == 1, atomic.LoadInt32(&lastRewriteOk) == 1, lastWriteOk
+}
+
+// writeRewrittenFile writes the given databases as an RDB preamble to a new file at path and returns it opened for
+// appending. The preamble holds values of every type and is more compact than the commands rebuilding them.
+func writeRewrittenFile(path string, databases []rdb.RDBDatabase) (*os.File, error) {
+	rewrittenFile, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE|os.O_TRUNC, 0644)
+	if err != nil {
+		return nil, fmt.Errorf("error in creating append only file: %s", err.Error())
+	}
+
+	writer := bufio.NewWriter(rewrittenFile)
+	err = rdb.EncodeRdb(writer, &rdb.RDBType{Databases: databases}, 0)
+	if err == nil {
+		err = writer.Flush()
+	}
+	if err == nil {
+		err = rewrittenFile.Sync()
+	}
+	if err != nil {
+		rewrittenFile.Close()
+		return nil, fmt.Errorf("error in writing append only file: %s", err.Error())
+	}
+	return rewrittenFile, nil
+}
+
+// aofFilePath returns the path of the append only file named fileName in dirPath.
+func aofFilePath(dirPath, fileName string) string {
+	if dirPath == "" {
+		return fileName
+	}
+	return dirPath + "/" + fileName
+}
+
+// countingReader counts the bytes read from the underlying reader.
+type countingReader struct {
+	rd io.Reader
+	count int64
+}
+
+func (r *countingReader) Read(data []byte) (int, error) {
+	n, err := r.rd.Read(data)
+	r.count += int64(n)
+	return n, err
+}
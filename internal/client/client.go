@@ -0,0 +1,185 @@
+package client
+
+import (
+	"fmt"
+	"net"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"memodb/internal/acl"
+	"memodb/internal/resp"
+)
+
+// Client holds the state of a single client connection. Its fields are only changed by the goroutine serving the
+// connection, through Update when other connections may read them, such as Name or Db reported by CLIENT LIST.
+type Client struct {
+	Id uint64
+	Conn net.Conn
+	// Addr and LocalAddr are the addresses of the client and of the listener it connected to.
+	Addr string
+	LocalAddr string
+	CreatedAt time.Time
+	Name string
+	// Protocol is the RESP version negotiated by the client using HELLO, clients start with RESP2.
+	Protocol int
+	// Db is the database selected with SELECT, clients start on database 0.
+	Db int
+	// IsMaster is set on the connection a replica keeps with its master, commands on it are never replied to.
+	IsMaster bool
+	// Authenticated is set once the connection authenticated, or when it was accepted while no password was required.
+	Authenticated bool
+	// User is the name of the ACL user the connection is logged in as, connections start as the default user.
+	User string
+	// IsReplica is set on the connection of a replica once it asked for the dataset with PSYNC.
+	IsReplica bool
+	// NoEvict is set with CLIENT NO-EVICT, the client is reported with the e flag.
+	NoEvict bool
+	// LastCommand is the name of the last command of the client, LastInteraction when it was received.
+	LastCommand string
+	LastInteraction time.Time
+	// CloseAfterReply is set when the connection has to be closed once the reply to the current command is sent.
+	CloseAfterReply bool
+
+	// mutex guards the fields read by other connections
+	mutex sync.Mutex
+
+	// outMutex guards output, the replies waiting to be sent, along with the state of the output buffer limits
+	outMutex sync.Mutex
+	output []byte
+	softLimitReachedAt time.Time
+	// flushing is set while a goroutine started by Send writes the output buffer
+	flushing bool
+	// outClosed is set once the connection is closed for reaching an output buffer limit or failing a write
+	outClosed bool
+	// writeMutex makes sure the output buffer is written by one goroutine at a time, in order
+	writeMutex sync.Mutex
+}
+
+var lastClientId uint64
+
+// NewClient returns the state of a newly accepted client connection.
+func NewClient(conn net.Conn) *Client {
+	now := time.Now()
+	c := &Client{
+		Id: atomic.AddUint64(&lastClientId, 1),
+		Conn: conn,
+		CreatedAt: now,
+		Protocol: 2,
+		User: acl.DefaultUsername,
+		LastCommand: "NULL",
+		LastInteraction: now,
+	}
+	if conn != nil {
+		c.Addr, c.LocalAddr = conn.RemoteAddr().String(), conn.LocalAddr().String()
+		if conn.LocalAddr().Network() == "unix" {
+			// like in Redis, unix socket clients are reported at the path of the socket
+			c.Addr, c.LocalAddr = c.LocalAddr + ":0", c.LocalAddr + ":0"
+		}
+	}
+	return c
+}
+
+// NewFakeClient returns a client without a connection, used to execute commands on behalf of the server itself such as
+// when replaying the append only file. Its replies are discarded.
+func NewFakeClient() *Client {
+	c := NewClient(nil)
+	c.Authenticated = true
+	return c
+}
+
+// IsInternal checks if the client runs commands on behalf of the server, the connection with our master or a fake
+// client. Internal clients are not subject to authentication nor ACL rules.
+func (c *Client) IsInternal() bool {
+	return c.IsMaster || c.Conn == nil
+}
+
+// Update runs update, which changes fields of the client read by other connections, under the lock they read them with.
+func (c *Client) Update(update func()) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	update()
+}
+
+// flags returns the flags of the client as reported by CLIENT LIST, M for our master, S for a replica, e for a client
+// with CLIENT NO-EVICT on and N for none of them. mutex must be held.
+func (c *Client) flags() string {
+	flags := ""
+	if c.IsMaster {
+		flags += "M"
+	}
+	if c.IsReplica {
+		flags += "S"
+	}
+	if c.NoEvict {
+		flags += "e"
+	}
+	if flags == "" {
+		flags = "N"
+	}
+	return flags
+}
+
+// Type returns the type of the client as understood by CLIENT LIST TYPE and CLIENT KILL TYPE, master for our master,
+// replica for a replica and normal for the others.
+func (c *Client) Type() string {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	switch {
+		case c.IsMaster: {
+			return "master"
+		}
+		case c.IsReplica: {
+			return "replica"
+		}
+		default: {
+			return "normal"
+		}
+	}
+}
+
+// LoggedInAs checks if the client is logged in as an ACL user.
+func (c *Client) LoggedInAs(user string) bool {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return c.User == user
+}
+
+// Info describes the client in the format of CLIENT LIST and CLIENT INFO.
+func (c *Client) Info() string {
+	outputMemory := c.PendingOutput()
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	now := time.Now()
+	return fmt.Sprintf("id=%d addr=%s laddr=%s name=%s age=%d idle=%d flags=%s db=%d omem=%d cmd=%s user=%s resp=%d",
+		c.Id, c.Addr, c.LocalAddr, c.Name, int(now.Sub(c.CreatedAt).Seconds()), int(now.Sub(c.LastInteraction).Seconds()),
+		c.flags(), c.Db, outputMemory, c.LastCommand, c.User, c.Protocol)
+}
+
+// SetIdleTimeout sets the read deadline of the connection so that it is closed once it stays idle for timeout, counted
+// from the last command. Our master and replicas, which may stay silent for long, never time out, nor does any client
+// when timeout is 0.
+func (c *Client) SetIdleTimeout(timeout time.Duration) error {
+	if c.Conn == nil {
+		return nil
+	}
+	c.mutex.Lock()
+	deadline := c.LastInteraction.Add(timeout)
+	if timeout <= 0 || c.IsMaster || c.IsReplica {
+		deadline = time.Time{}
+	}
+	c.mutex.Unlock()
+	return c.Conn.SetReadDeadline(deadline)
+}
+
+// Kill closes the connection of the client, the goroutine serving it stops at its next read or write.
+func (c *Client) Kill() {
+	if c.Conn != nil {
+		c.Conn.Close()
+	}
+}
+
+// Serialize converts a reply into RESP understood by the protocol version the client negotiated.
+func (c *Client) Serialize(reply resp.RespType) (string, error) {
+	return resp.SerializeRespProtocol(reply, c.Protocol)
+}
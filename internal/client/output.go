@@ -0,0 +1,176 @@
+package client
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// ErrOutputBufferLimit is returned when a reply does not fit in the output buffer of a client, whose connection is
+// then closed.
+var ErrOutputBufferLimit = errors.New("client output buffer limit reached")
+
+// OutputBufferLimit bounds the replies waiting to be sent to a client. The connection is closed as soon as they reach
+// Hard bytes, or once they stay above Soft bytes for SoftSeconds. A limit of 0 disables the check.
+type OutputBufferLimit struct {
+	Hard int64
+	Soft int64
+	SoftSeconds int64
+}
+
+var (
+	// limitsMutex guards outputBufferLimits
+	limitsMutex sync.RWMutex
+	// outputBufferLimits holds the output buffer limit of each client type, normal, replica and pubsub
+	outputBufferLimits = map[string]OutputBufferLimit{}
+)
+
+// SetOutputBufferLimits sets the output buffer limits of the client types, as done by client-output-buffer-limit.
+func SetOutputBufferLimits(limits map[string]OutputBufferLimit) {
+	limitsMutex.Lock()
+	defer limitsMutex.Unlock()
+	outputBufferLimits = limits
+}
+
+// Write appends a reply to the output buffer of the client, it is sent by the next Flush. When the output buffer
+// limit of the client is reached its connection is closed and ErrOutputBufferLimit is returned.
+func (c *Client) Write(data []byte) error {
+	if c.Conn == nil {
+		return nil
+	}
+	c.outMutex.Lock()
+	defer c.outMutex.Unlock()
+	if c.outClosed {
+		return ErrOutputBufferLimit
+	}
+	c.output = append(c.output, data...)
+	if c.outputLimitReached() {
+		fmt.Printf("Client %s closed for overcoming of output buffer limits.\n", c.Addr)
+		c.outClosed = true
+		c.output = nil
+		c.Kill()
+		return ErrOutputBufferLimit
+	}
+	return nil
+}
+
+// Flush sends the replies waiting in the output buffer of the client.
+func (c *Client) Flush() error {
+	c.writeMutex.Lock()
+	defer c.writeMutex.Unlock()
+	return c.flush()
+}
+
+// flush sends the replies waiting in the output buffer, writeMutex must be held so replies are sent in order.
+func (c *Client) flush() error {
+	c.outMutex.Lock()
+	output := c.output
+	c.output = nil
+	c.softLimitReachedAt = time.Time{}
+	c.outMutex.Unlock()
+
+	if len(output) == 0 {
+		return nil
+	}
+	_, err := c.Conn.Write(output)
+	return err
+}
+
+// FlushAndKill sends the replies waiting in the output buffer of the client, giving up once timeout elapsed, then closes
+// its connection.
+func (c *Client) FlushAndKill(timeout time.Duration) {
+	if c.Conn == nil {
+		return
+	}
+	// a client that does not read its replies must not hold us for longer
+	c.Conn.SetWriteDeadline(time.Now().Add(timeout))
+	c.Flush()
+	c.Kill()
+}
+
+// Send appends data to the output buffer of the client and sends it from a separate goroutine, which is how commands
+// are streamed to replicas without waiting for them.
+func (c *Client) Send(data []byte) error {
+	if err := c.Write(data); err != nil {
+		return err
+	}
+	c.outMutex.Lock()
+	defer c.outMutex.Unlock()
+	if c.flushing {
+		// the running flush sends the data as well
+		return nil
+	}
+	c.flushing = true
+
+	go func() {
+		for {
+			err := c.Flush()
+			c.outMutex.Lock()
+			if err != nil {
+				// nothing more can be sent on the connection
+				c.outClosed = true
+				c.output = nil
+			}
+			if len(c.output) == 0 || c.outClosed {
+				c.flushing = false
+				c.outMutex.Unlock()
+				if err != nil {
+					fmt.Printf("Error writing to client %s: %s\n", c.Addr, err.Error())
+					c.Kill()
+				}
+				return
+			}
+			c.outMutex.Unlock()
+		}
+	}()
+	return nil
+}
+
+// WriteAhead sends the data returned by produce ahead of the output buffer, bypassing the output buffer limits. Data
+// written to the output buffer while produce runs, or while its data is sent, is sent after it. It is used for the RDB
+// snapshot sent to a replica, which the commands executed since it was taken follow.
+func (c *Client) WriteAhead(produce func() ([]byte, error)) error {
+	c.writeMutex.Lock()
+	defer c.writeMutex.Unlock()
+	data, err := produce()
+	if err != nil {
+		return err
+	}
+	_, err = c.Conn.Write(data)
+	return err
+}
+
+// PendingOutput returns the number of bytes waiting in the output buffer of the client.
+func (c *Client) PendingOutput() int {
+	c.outMutex.Lock()
+	defer c.outMutex.Unlock()
+	return len(c.output)
+}
+
+// OutputSent checks if everything written to the output buffer of the client was sent on its connection.
+func (c *Client) OutputSent() bool {
+	c.outMutex.Lock()
+	defer c.outMutex.Unlock()
+	return len(c.output) == 0 && !c.flushing
+}
+
+// outputLimitReached checks if the output buffer of the client reached the limit of its type, outMutex must be held.
+func (c *Client) outputLimitReached() bool {
+	limitsMutex.RLock()
+	limit := outputBufferLimits[c.Type()]
+	limitsMutex.RUnlock()
+
+	size := int64(len(c.output))
+	if limit.Hard > 0 && size >= limit.Hard {
+		return true
+	}
+	if limit.Soft == 0 || size < limit.Soft {
+		c.softLimitReachedAt = time.Time{}
+		return false
+	}
+	if c.softLimitReachedAt.IsZero() {
+		c.softLimitReachedAt = time.Now()
+	}
+	return time.Since(c.softLimitReachedAt) >= time.Duration(limit.SoftSeconds) * time.Second
+}
@@ -0,0 +1,41 @@
+package client
+
+import (
+	"sort"
+	"sync"
+)
+
+var (
+	// registryMutex guards clients
+	registryMutex sync.RWMutex
+	// clients holds the connected clients, keyed by their id
+	clients = map[uint64]*Client{}
+)
+
+// Register adds a newly accepted client to the clients reported by CLIENT LIST.
+func Register(c *Client) {
+	registryMutex.Lock()
+	defer registryMutex.Unlock()
+	clients[c.Id] = c
+}
+
+// Unregister removes a client whose connection is closed.
+func Unregister(c *Client) {
+	registryMutex.Lock()
+	defer registryMutex.Unlock()
+	delete(clients, c.Id)
+}
+
+// Clients returns the connected clients sorted by id.
+func Clients() []*Client {
+	registryMutex.RLock()
+	defer registryMutex.RUnlock()
+	sorted := make([]*Client, 0, len(clients))
+	for _, c := range clients {
+		sorted = append(sorted, c)
+	}
+	sort.Slice(sorted, func(i, j int) bool {
+		return sorted[i].Id < sorted[j].Id
+	})
+	return sorted
+}
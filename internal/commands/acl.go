@@ -0,0 +1,214 @@
+package commands
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"memodb/internal/acl"
+	"memodb/internal/client"
+	"memodb/internal/config"
+	"memodb/internal/resp"
+)
+
+func init() {
+	acl.SetCommandLookup(func(command, subcommand string) bool {
+		found := LookupCommand(command)
+		if found == nil || subcommand == "" {
+			return found != nil
+		}
+		return found.Subcommands[strings.ToUpper(subcommand)] != nil
+	})
+}
+
+// AclSetuser function handles the ACL SETUSER username [rule ...] command by creating or modifying an ACL user.
+func AclSetuser(c *client.Client, arguments []string) (string, error) {
+	if err := acl.SetUser(arguments[0], arguments[1:]...); err != nil {
+		return "", err
+	}
+	return c.Serialize(resp.NewSimpleString("OK"))
+}
+
+// AclGetuser function handles the ACL GETUSER username command by replying with the rules of a user, or a null reply
+// if there is no such user.
+func AclGetuser(c *client.Client, arguments []string) (string, error) {
+	user := acl.Lookup(arguments[0])
+	if user == nil {
+		return c.Serialize(resp.NewNull())
+	}
+	return c.Serialize(resp.NewMap(
+		resp.NewBulkString("flags"), resp.NewBulkStringArray(user.Flags()...),
+		resp.NewBulkString("passwords"), resp.NewBulkStringArray(user.Passwords()...),
+		resp.NewBulkString("commands"), resp.NewBulkString(user.CommandRules()),
+		resp.NewBulkString("keys"), resp.NewBulkString(user.KeyRules()),
+		resp.NewBulkString("channels"), resp.NewBulkString(user.ChannelRules()),
+		resp.NewBulkString("selectors"), resp.NewArray(),
+	))
+}
+
+// AclDeluser function handles the ACL DELUSER username [username ...] command by removing users, it replies with the
+// number of users removed. Connections logged in as a removed user are closed.
+func AclDeluser(c *client.Client, arguments []string) (string, error) {
+	deleted := 0
+	for _, username := range arguments {
+		if username == acl.DefaultUsername {
+			return "", fmt.Errorf("ERR The 'default' user cannot be removed")
+		}
+	}
+	for _, username := range arguments {
+		// the default user was ruled out above, which is the only error
+		if isPresent, _ := acl.DeleteUser(username); isPresent {
+			deleted++
+			killClients(c, false, func(other *client.Client) bool {
+				return other.LoggedInAs(username)
+			})
+		}
+	}
+	return c.Serialize(resp.NewInteger(deleted))
+}
+
+// AclList function handles the ACL LIST command by replying with the rules of every user, in the ACL file format.
+func AclList(c *client.Client, arguments []string) (string, error) {
+	descriptions := []string{}
+	for _, user := range acl.Users() {
+		descriptions = append(descriptions, user.Describe())
+	}
+	return c.Serialize(resp.NewBulkStringArray(descriptions...))
+}
+
+// AclUsers function handles the ACL USERS command by replying with the name of every user.
+func AclUsers(c *client.Client, arguments []string) (string, error) {
+	names := []string{}
+	for _, user := range acl.Users() {
+		names = append(names, user.Name)
+	}
+	return c.Serialize(resp.NewBulkStringArray(names...))
+}
+
+// AclWhoami function handles the ACL WHOAMI command by replying with the user the connection is logged in as.
+func AclWhoami(c *client.Client, arguments []string) (string, error) {
+	return c.Serialize(resp.NewBulkString(c.User))
+}
+
+// AclCat function handles the ACL CAT [category] command by replying with the ACL categories, or the commands of a
+// category.
+func AclCat(c *client.Client, arguments []string) (string, error) {
+	if len(arguments) == 0 {
+		return c.Serialize(resp.NewBulkStringArray(acl.Categories()...))
+	}
+	category := strings.ToLower(arguments[0])
+	if category == "all" || !acl.IsCategory(category) {
+		return "", fmt.Errorf("ERR Unknown category '%s'", arguments[0])
+	}
+
+	names := []string{}
+	addIfInCategory := func(command *Command) {
+		for _, commandCategory := range command.AclCategories() {
+			if commandCategory == "@" + category {
+				names = append(names, command.Name)
+				return
+			}
+		}
+	}
+	for _, command := range AllCommands() {
+		if command.Handler != nil {
+			addIfInCategory(command)
+		}
+		for _, subcommand := range command.SortedSubcommands() {
+			addIfInCategory(subcommand)
+		}
+	}
+	return c.Serialize(resp.NewBulkStringArray(names...))
+}
+
+// AclLog function handles the ACL LOG [count | RESET] command by replying with the most recent denials, 10 unless
+// count is given, or emptying the log.
+func AclLog(c *client.Client, arguments []string) (string, error) {
+	if len(arguments) > 1 {
+		return "", ErrSyntax
+	}
+	count := 10
+	if len(arguments) == 1 {
+		if strings.ToUpper(arguments[0]) == "RESET" {
+			acl.ResetLog()
+			return c.Serialize(resp.NewSimpleString("OK"))
+		}
+		var err error
+		count, err = strconv.Atoi(arguments[0])
+		if err != nil || count < 0 {
+			return "", fmt.Errorf("ERR value is out of range, must be positive")
+		}
+	}
+
+	now := time.Now().UnixMilli()
+	entries := []resp.RespType{}
+	for _, entry := range acl.LogEntries(count) {
+		entries = append(entries, resp.NewMap(
+			resp.NewBulkString("count"), resp.NewInteger(entry.Count),
+			resp.NewBulkString("reason"), resp.NewBulkString(entry.Reason),
+			resp.NewBulkString("context"), resp.NewBulkString(entry.Context),
+			resp.NewBulkString("object"), resp.NewBulkString(entry.Object),
+			resp.NewBulkString("username"), resp.NewBulkString(entry.Username),
+			resp.NewBulkString("age-seconds"), resp.NewDouble(float64(now - entry.CreatedAt) / 1000),
+			resp.NewBulkString("client-info"), resp.NewBulkString(entry.ClientInfo),
+			resp.NewBulkString("entry-id"), resp.NewInteger(int(entry.EntryId)),
+			resp.NewBulkString("timestamp-created"), resp.NewInteger(int(entry.CreatedAt)),
+			resp.NewBulkString("timestamp-last-updated"), resp.NewInteger(int(entry.UpdatedAt)),
+		))
+	}
+	return c.Serialize(resp.NewArray(entries...))
+}
+
+// AclLoad function handles the ACL LOAD command by replacing every user with those of the aclfile.
+func AclLoad(c *client.Client, arguments []string) (string, error) {
+	path := config.Get("aclfile")
+	if path == "" {
+		return "", fmt.Errorf("ERR This Redis instance is not configured to use an ACL file.")
+	}
+	if err := acl.LoadFile(path); err != nil {
+		return "", err
+	}
+	return c.Serialize(resp.NewSimpleString("OK"))
+}
+
+// AclSave function handles the ACL SAVE command by writing every user to the aclfile.
+func AclSave(c *client.Client, arguments []string) (string, error) {
+	path := config.Get("aclfile")
+	if path == "" {
+		return "", fmt.Errorf("ERR This Redis instance is not configured to use an ACL file.")
+	}
+	if err := acl.SaveFile(path); err != nil {
+		fmt.Println("Error saving the ACL file:", err.Error())
+		return "", fmt.Errorf("ERR There was an error trying to save the ACLs. Please check the server logs for more information")
+	}
+	return c.Serialize(resp.NewSimpleString("OK"))
+}
+
+// checkPermissions checks that the user of the connection may run a command and access its keys, argv includes the
+// command (and subcommand) name. Denials are recorded in the ACL log.
+func checkPermissions(c *client.Client, command *Command, argv []string) error {
+	if c.IsInternal() || command.HasFlag(FlagNoAuth) {
+		return nil
+	}
+	user := acl.Lookup(c.User)
+	if user == nil {
+		// the user was removed since the connection logged in
+		c.CloseAfterReply = true
+		return ErrNoAuth
+	}
+
+	container, subcommand, _ := strings.Cut(command.Name, "|")
+	if !user.CanRunCommand(container, subcommand, command.AclCategories()) {
+		acl.AddLogEntry("command", command.Name, user.Name, c.Info())
+		return fmt.Errorf("NOPERM User %s has no permissions to run the '%s' command", user.Name, command.Name)
+	}
+	write := command.HasFlag(FlagWrite)
+	for _, position := range command.KeyPositions(argv) {
+		if !user.CanAccessKey(argv[position], write) {
+			acl.AddLogEntry("key", argv[position], user.Name, c.Info())
+			return fmt.Errorf("NOPERM No permissions to access a key")
+		}
+	}
+	return nil
+}
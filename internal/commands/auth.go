@@ -0,0 +1,60 @@
+package commands
+
+import (
+	"fmt"
+
+	"memodb/internal/acl"
+	"memodb/internal/client"
+	"memodb/internal/resp"
+)
+
+// Auth function handles the AUTH [username] password command by logging the connection in as an ACL user. Without a
+// username the connection logs in as the default user, whose password is requirepass.
+func Auth(c *client.Client, arguments []string) (string, error) {
+	if len(arguments) > 2 {
+		return "", ErrSyntax
+	}
+	if len(arguments) == 1 && !PasswordRequired() {
+		return "", fmt.Errorf("ERR AUTH <password> called without any password configured for the default user. Are you sure your configuration is correct?")
+	}
+
+	username, password := acl.DefaultUsername, arguments[0]
+	if len(arguments) == 2 {
+		username, password = arguments[0], arguments[1]
+	}
+	if err := authenticate(c, username, password); err != nil {
+		return "", err
+	}
+	return c.Serialize(resp.NewSimpleString("OK"))
+}
+
+// Quit function handles the QUIT command by closing the connection once the reply is sent.
+func Quit(c *client.Client, arguments []string) (string, error) {
+	c.CloseAfterReply = true
+	return c.Serialize(resp.NewSimpleString("OK"))
+}
+
+// PasswordRequired checks if the default user requires a password, in which case connections have to authenticate.
+func PasswordRequired() bool {
+	user := acl.DefaultUser()
+	return !user.NoPass || !user.Enabled
+}
+
+// AuthRequired checks if a connection has to authenticate before running commands other than AUTH, HELLO and QUIT.
+func AuthRequired(c *client.Client) bool {
+	return !c.Authenticated && !c.IsInternal() && PasswordRequired()
+}
+
+// authenticate checks the credentials of an ACL user and logs the connection in as that user when they are valid.
+// Failures are recorded in the ACL log.
+func authenticate(c *client.Client, username, password string) error {
+	if acl.Authenticate(username, password) == nil {
+		acl.AddLogEntry("auth", "AUTH", username, c.Info())
+		return ErrWrongPass
+	}
+	c.Update(func() {
+		c.User = username
+		c.Authenticated = true
+	})
+	return nil
+}
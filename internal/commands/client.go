@@ -0,0 +1,335 @@
+package commands
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"memodb/internal/client"
+	"memodb/internal/resp"
+)
+
+var (
+	// pauseMutex guards pauseEnd, pauseAll and unpaused
+	pauseMutex sync.Mutex
+	// pauseEnd is when the pause set with CLIENT PAUSE ends, pauseAll tells whether it holds every command or writes only
+	pauseEnd time.Time
+	pauseAll bool
+	// shutdownPause holds write commands while a shutdown is in progress, until it is aborted
+	shutdownPause bool
+	// unpaused is closed when CLIENT UNPAUSE or an aborted shutdown ends a pause early
+	unpaused = make(chan struct{})
+)
+
+// ClientId function handles the CLIENT ID command by replying with the id of the connection.
+func ClientId(c *client.Client, arguments []string) (string, error) {
+	return c.Serialize(resp.NewInteger(int(c.Id)))
+}
+
+// ClientInfo function handles the CLIENT INFO command by replying with the description of the connection.
+func ClientInfo(c *client.Client, arguments []string) (string, error) {
+	return c.Serialize(resp.NewVerbatimString("txt", c.Info() + "\n"))
+}
+
+// ClientList function handles the CLIENT LIST [TYPE normal|master|replica|pubsub] [ID id [id ...]] command by replying
+// with the description of every connection, or of those of a type or with the given ids.
+func ClientList(c *client.Client, arguments []string) (string, error) {
+	clientType := ""
+	ids := map[uint64]bool{}
+	if len(arguments) > 0 {
+		switch strings.ToUpper(arguments[0]) {
+			case "TYPE": {
+				if len(arguments) != 2 {
+					return "", ErrSyntax
+				}
+				var err error
+				if clientType, err = parseClientType(arguments[1]); err != nil {
+					return "", err
+				}
+			}
+			case "ID": {
+				if len(arguments) < 2 {
+					return "", ErrSyntax
+				}
+				for _, argument := range arguments[1:] {
+					id, err := strconv.ParseUint(argument, 10, 64)
+					if err != nil || id == 0 {
+						return "", fmt.Errorf("ERR Invalid client ID")
+					}
+					ids[id] = true
+				}
+			}
+			default: {
+				return "", ErrSyntax
+			}
+		}
+	}
+
+	var list strings.Builder
+	for _, other := range client.Clients() {
+		if (clientType != "" && other.Type() != clientType) || (len(ids) > 0 && !ids[other.Id]) {
+			continue
+		}
+		list.WriteString(other.Info())
+		list.WriteByte('\n')
+	}
+	return c.Serialize(resp.NewVerbatimString("txt", list.String()))
+}
+
+// ClientSetname function handles the CLIENT SETNAME name command by naming the connection, an empty name removes it.
+func ClientSetname(c *client.Client, arguments []string) (string, error) {
+	if !isValidClientName(arguments[0]) {
+		return "", fmt.Errorf("ERR Client names cannot contain spaces, newlines or special characters.")
+	}
+	c.Update(func() { c.Name = arguments[0] })
+	return c.Serialize(resp.NewSimpleString("OK"))
+}
+
+// ClientGetname function handles the CLIENT GETNAME command by replying with the name of the connection, or a null
+// reply when it has none.
+func ClientGetname(c *client.Client, arguments []string) (string, error) {
+	if c.Name == "" {
+		return c.Serialize(resp.NewNullBulkString())
+	}
+	return c.Serialize(resp.NewBulkString(c.Name))
+}
+
+// ClientKill function handles the CLIENT KILL command by closing connections. The CLIENT KILL addr:port form closes
+// the connection of that address and replies OK. The CLIENT KILL <filter> <value> ... form closes the connections
+// matching every filter among ID, ADDR, LADDR, USER, TYPE and MAXAGE, the caller's one only when SKIPME is no, and
+// replies with the number of connections closed.
+func ClientKill(c *client.Client, arguments []string) (string, error) {
+	if len(arguments) == 1 {
+		killed := killClients(c, false, func(other *client.Client) bool {
+			return other.Addr == arguments[0]
+		})
+		if killed == 0 {
+			return "", fmt.Errorf("ERR No such client")
+		}
+		return c.Serialize(resp.NewSimpleString("OK"))
+	}
+	if len(arguments) % 2 != 0 {
+		return "", ErrSyntax
+	}
+
+	filters := []func(other *client.Client) bool{}
+	skipMe := true
+	for idx := 0; idx < len(arguments); idx += 2 {
+		val := arguments[idx + 1]
+		switch strings.ToUpper(arguments[idx]) {
+			case "ID": {
+				id, err := strconv.ParseUint(val, 10, 64)
+				if err != nil || id == 0 {
+					return "", fmt.Errorf("ERR client-id should be greater than 0")
+				}
+				filters = append(filters, func(other *client.Client) bool { return other.Id == id })
+			}
+			case "ADDR": {
+				filters = append(filters, func(other *client.Client) bool { return other.Addr == val })
+			}
+			case "LADDR": {
+				filters = append(filters, func(other *client.Client) bool { return other.LocalAddr == val })
+			}
+			case "USER": {
+				filters = append(filters, func(other *client.Client) bool { return other.LoggedInAs(val) })
+			}
+			case "TYPE": {
+				clientType, err := parseClientType(val)
+				if err != nil {
+					return "", err
+				}
+				filters = append(filters, func(other *client.Client) bool { return other.Type() == clientType })
+			}
+			case "MAXAGE": {
+				maxAge, err := strconv.ParseInt(val, 10, 64)
+				if err != nil || maxAge < 0 {
+					return "", ErrNotInteger
+				}
+				filters = append(filters, func(other *client.Client) bool {
+					return time.Since(other.CreatedAt) > time.Duration(maxAge) * time.Second
+				})
+			}
+			case "SKIPME": {
+				switch strings.ToLower(val) {
+					case "yes": {
+						skipMe = true
+					}
+					case "no": {
+						skipMe = false
+					}
+					default: {
+						return "", ErrSyntax
+					}
+				}
+			}
+			default: {
+				return "", ErrSyntax
+			}
+		}
+	}
+
+	killed := killClients(c, skipMe, func(other *client.Client) bool {
+		for _, filter := range filters {
+			if !filter(other) {
+				return false
+			}
+		}
+		return true
+	})
+	return c.Serialize(resp.NewInteger(killed))
+}
+
+// ClientPause function handles the CLIENT PAUSE timeout [WRITE | ALL] command by holding the commands of the clients,
+// or only their write commands, for timeout milliseconds. Our master, the replicas and CLIENT UNPAUSE are never held.
+func ClientPause(c *client.Client, arguments []string) (string, error) {
+	if len(arguments) > 2 {
+		return "", ErrSyntax
+	}
+	timeout, err := strconv.ParseInt(arguments[0], 10, 64)
+	if err != nil {
+		return "", fmt.Errorf("ERR timeout is not an integer or out of range")
+	}
+	if timeout < 0 {
+		return "", fmt.Errorf("ERR timeout is negative")
+	}
+	all := true
+	if len(arguments) == 2 {
+		switch strings.ToUpper(arguments[1]) {
+			case "ALL": {
+				all = true
+			}
+			case "WRITE": {
+				all = false
+			}
+			default: {
+				return "", ErrSyntax
+			}
+		}
+	}
+
+	end := time.Now().Add(time.Duration(timeout) * time.Millisecond)
+	pauseMutex.Lock()
+	defer pauseMutex.Unlock()
+	if time.Now().Before(pauseEnd) {
+		// a pause in progress is only made longer or stricter
+		if end.After(pauseEnd) {
+			pauseEnd = end
+		}
+		pauseAll = pauseAll || all
+	} else {
+		pauseEnd, pauseAll = end, all
+	}
+	return c.Serialize(resp.NewSimpleString("OK"))
+}
+
+// ClientUnpause function handles the CLIENT UNPAUSE command by ending the pause set with CLIENT PAUSE.
+func ClientUnpause(c *client.Client, arguments []string) (string, error) {
+	pauseMutex.Lock()
+	defer pauseMutex.Unlock()
+	pauseEnd = time.Time{}
+	close(unpaused)
+	unpaused = make(chan struct{})
+	return c.Serialize(resp.NewSimpleString("OK"))
+}
+
+// ClientNoEvict function handles the CLIENT NO-EVICT on|off command by flagging the connection as one that must not
+// be evicted. The flag is only reported, clients are never evicted.
+func ClientNoEvict(c *client.Client, arguments []string) (string, error) {
+	switch strings.ToLower(arguments[0]) {
+		case "on": {
+			c.Update(func() { c.NoEvict = true })
+		}
+		case "off": {
+			c.Update(func() { c.NoEvict = false })
+		}
+		default: {
+			return "", ErrSyntax
+		}
+	}
+	return c.Serialize(resp.NewSimpleString("OK"))
+}
+
+// pauseWritesForShutdown holds the write commands of the clients while the server shuts down, or releases them when
+// the shutdown is aborted. Like CLIENT PAUSE WRITE, our master and the replicas are not held.
+func pauseWritesForShutdown(paused bool) {
+	pauseMutex.Lock()
+	defer pauseMutex.Unlock()
+	shutdownPause = paused
+	if !paused {
+		close(unpaused)
+		unpaused = make(chan struct{})
+	}
+}
+
+// waitWhilePaused holds a command until the pause set with CLIENT PAUSE, or by a shutdown, ends when the pause applies
+// to it.
+func waitWhilePaused(c *client.Client, command *Command) {
+	if c.IsInternal() || c.IsReplica || command.Name == "client|unpause" {
+		return
+	}
+	blocked := false
+	defer func() {
+		if blocked {
+			atomic.AddInt64(&blockedClients, -1)
+		}
+	}()
+	for {
+		pauseMutex.Lock()
+		end, all, heldForShutdown, done := pauseEnd, pauseAll, shutdownPause, unpaused
+		pauseMutex.Unlock()
+
+		write := command.HasFlag(FlagWrite)
+		remaining := time.Until(end)
+		if !(heldForShutdown && write) && (remaining <= 0 || (!all && !write)) {
+			return
+		}
+		if !blocked {
+			blocked = true
+			atomic.AddInt64(&blockedClients, 1)
+		}
+		if heldForShutdown && write {
+			// the shutdown pause has no end, the server exits unless the shutdown is aborted
+			<-done
+			continue
+		}
+		timer := time.NewTimer(remaining)
+		select {
+			case <-timer.C:
+			case <-done:
+		}
+		timer.Stop()
+	}
+}
+
+// killClients closes the connections of the clients matching match and returns their number. The connection of c is
+// closed once the reply is sent, unless skipMe is set.
+func killClients(c *client.Client, skipMe bool, match func(other *client.Client) bool) int {
+	killed := 0
+	for _, other := range client.Clients() {
+		if (other == c && skipMe) || !match(other) {
+			continue
+		}
+		if other == c {
+			c.CloseAfterReply = true
+		} else {
+			other.Kill()
+		}
+		killed++
+	}
+	return killed
+}
+
+// parseClientType parses a client type given to CLIENT LIST or CLIENT KILL, slave being a synonym of replica.
+func parseClientType(val string) (string, error) {
+	clientType := strings.ToLower(val)
+	if clientType == "slave" {
+		clientType = "replica"
+	}
+	if clientType != "normal" && clientType != "master" && clientType != "replica" && clientType != "pubsub" {
+		return "", fmt.Errorf("ERR Unknown client type '%s'", val)
+	}
+	return clientType, nil
+}
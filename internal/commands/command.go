@@ -0,0 +1,119 @@
+package commands
+
+import (
+	"strings"
+
+	"memodb/internal/client"
+	"memodb/internal/resp"
+)
+
+// CommandList function handles the COMMAND command by replying with the details of every command.
+func CommandList(c *client.Client, arguments []string) (string, error) {
+	details := []resp.RespType{}
+	for _, command := range AllCommands() {
+		details = append(details, commandDetails(command))
+	}
+	return c.Serialize(resp.NewArray(details...))
+}
+
+// CommandCount function handles the COMMAND COUNT command by replying with the number of commands.
+func CommandCount(c *client.Client, arguments []string) (string, error) {
+	return c.Serialize(resp.NewInteger(len(commandTable)))
+}
+
+// CommandInfo function handles the COMMAND INFO [command-name ...] command by replying with the details of the given
+// commands, or of every command when none is given. Unknown commands are replied as null.
+func CommandInfo(c *client.Client, arguments []string) (string, error) {
+	if len(arguments) == 0 {
+		return CommandList(c, arguments)
+	}
+
+	details := []resp.RespType{}
+	for _, name := range arguments {
+		command := lookupCommandOrSubcommand(name)
+		if command == nil {
+			details = append(details, resp.NewNullArray())
+			continue
+		}
+		details = append(details, commandDetails(command))
+	}
+	return c.Serialize(resp.NewArray(details...))
+}
+
+// CommandDocs function handles the COMMAND DOCS [command-name ...] command by replying with a map of the documentation
+// of the given commands, or of every command when none is given. Unknown commands are skipped.
+func CommandDocs(c *client.Client, arguments []string) (string, error) {
+	commands := []*Command{}
+	if len(arguments) == 0 {
+		commands = AllCommands()
+	}
+	for _, name := range arguments {
+		if command := lookupCommandOrSubcommand(name); command != nil {
+			commands = append(commands, command)
+		}
+	}
+
+	docs := []resp.RespType{}
+	for _, command := range commands {
+		docs = append(docs, resp.NewBulkString(command.Name), commandDocs(command))
+	}
+	return c.Serialize(resp.NewMap(docs...))
+}
+
+// lookupCommandOrSubcommand returns the command with the given name, subcommands are named container|subcommand.
+func lookupCommandOrSubcommand(name string) *Command {
+	containerName, subcommandName, isSubcommand := strings.Cut(name, "|")
+	command := LookupCommand(containerName)
+	if command == nil || !isSubcommand {
+		return command
+	}
+	return command.Subcommands[strings.ToUpper(subcommandName)]
+}
+
+// commandDetails returns the details of a command in the format of COMMAND INFO: name, arity, flags, first key,
+// last key, key step, ACL categories, tips, key specifications and subcommands.
+func commandDetails(command *Command) resp.RespType {
+	flags := []resp.RespType{}
+	for _, name := range command.FlagNames() {
+		flags = append(flags, resp.NewSimpleString(name))
+	}
+	categories := []resp.RespType{}
+	for _, category := range command.AclCategories() {
+		categories = append(categories, resp.NewSimpleString(category))
+	}
+	subcommands := []resp.RespType{}
+	for _, subcommand := range command.SortedSubcommands() {
+		subcommands = append(subcommands, commandDetails(subcommand))
+	}
+
+	return resp.NewArray(
+		resp.NewBulkString(command.Name),
+		resp.NewInteger(command.Arity),
+		resp.NewSet(flags...),
+		resp.NewInteger(command.FirstKey),
+		resp.NewInteger(command.LastKey),
+		resp.NewInteger(command.KeyStep),
+		resp.NewSet(categories...),
+		resp.NewArray(),
+		resp.NewArray(),
+		resp.NewArray(subcommands...),
+	)
+}
+
+// commandDocs returns the documentation of a command in the format of COMMAND DOCS.
+func commandDocs(command *Command) resp.RespType {
+	docs := []resp.RespType{
+		resp.NewBulkString("summary"), resp.NewBulkString(command.Summary),
+		resp.NewBulkString("since"), resp.NewBulkString(command.Since),
+		resp.NewBulkString("group"), resp.NewBulkString(command.Group),
+		resp.NewBulkString("complexity"), resp.NewBulkString(command.Complexity),
+	}
+	if len(command.Subcommands) > 0 {
+		subcommandDocs := []resp.RespType{}
+		for _, subcommand := range command.SortedSubcommands() {
+			subcommandDocs = append(subcommandDocs, resp.NewBulkString(subcommand.Name), commandDocs(subcommand))
+		}
+		docs = append(docs, resp.NewBulkString("subcommands"), resp.NewMap(subcommandDocs...))
+	}
+	return resp.NewMap(docs...)
+}
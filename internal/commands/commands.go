@@ -1,157 +1,141 @@
 package commands
 
 import (
-	"encoding/hex"
 	"fmt"
-	"net"
 	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
 
+	"memodb/internal/aof"
+	"memodb/internal/client"
 	"memodb/internal/resp"
+	"memodb/internal/worker"
 )
 
+// writeMutex serializes the write commands along with their propagation, so the append only file and the replicas get
+// them in the order they were executed. It is also held while the snapshots a rewritten append only file or a replica
+// start from are taken, so no write is both part of a snapshot and appended or streamed after it.
+var writeMutex sync.Mutex
+
 // HandleCommand function handles the different Redis commands sent by the clients.
-func HandleCommand(clientConn net.Conn, buffer []byte) (bool, bool, error) {
-	respMsg, err := resp.DeserializeResp(buffer)
-	
-	// error in deserializing the message
+// It returns whether the command succeeded. Write commands that succeeded are appended to the append only file and
+// propagated to the replicas. Errors raised by the command are replied to the client, the returned error is only set
+// when the reply could not be buffered. Replies are sent once the client output buffer is flushed.
+func HandleCommand(c *client.Client, respMsg *resp.RespType) (bool, error) {
+	arrayElems, err := commandArguments(respMsg)
 	if err != nil {
-		return false, false, err
+		return false, ReplyError(c, err)
+	}
+	if len(arrayElems) == 0 {
+		// empty commands are ignored
+		return true, nil
 	}
 
-	var response string
-	propagateCommand := false
-	switch respMsg.DataType {
-		case resp.Array: {
-			var arrayElems []string
-			for i := 0; i < len(respMsg.Array); i++ {
-				elemDataType := respMsg.Array[i].DataType
+	command, arguments, err := resolveCommand(arrayElems)
+	c.Update(func() {
+		c.LastInteraction = time.Now()
+		if command != nil {
+			c.LastCommand = command.Name
+		}
+	})
+	if err != nil {
+		return false, ReplyError(c, err)
+	}
 
-				switch elemDataType {
-					case resp.BulkString: {
-						arrayElems = append(arrayElems, respMsg.Array[i].String)
-					}
-				}
-			}
+	if AuthRequired(c) && !command.HasFlag(FlagNoAuth) {
+		return false, ReplyError(c, ErrNoAuth)
+	}
+	if err := checkPermissions(c, command, arrayElems); err != nil {
+		return false, ReplyError(c, err)
+	}
+	waitWhilePaused(c, command)
 
-			command := strings.ToUpper(arrayElems[0])
-			arguments := arrayElems[1:]
-			switch command {
-				case "PING": {
-					response, err = Ping()
-					if err != nil {
-						return false, propagateCommand, err
-					}
-				}
-				case "ECHO": {
-					response, err = Echo(arguments)
-					if err != nil {
-						return false, propagateCommand, err
-					}
-				}
-				case "SET": {
-					response, err = Set(arguments)
-					propagateCommand = true
-					if err != nil {
-						return false, propagateCommand, err
-					}
-				}
-				case "GET": {
-					response, err = Get(arguments)
-					if err != nil {
-						return false, propagateCommand, err
-					}
-				}
-				case "KEYS": {
-					switch arguments[0] {
-						case "*": {
-							response, err =	Keys("*")
-							if err != nil {
-								return false, propagateCommand, err
-							}
-						}
-						default: {
-							return false, propagateCommand, fmt.Errorf("unknown command")
-						}
-					}
-				}
-				case "CONFIG": {
-					switch arguments[0] {
-						case "GET": {
-							response, err = ConfigGet(arguments[1])
-							if err != nil {
-								return false, false, err
-							}
-						}
-						default: {
-							return false, propagateCommand, fmt.Errorf("unknown command")
-						}
-					}
-				}
-				case "INFO": {
-					switch arguments[0] {
-						case "replication": {
-							response, err = InfoReplication()
-							if err != nil {
-								return false, false, err
-							}
-						}
-						default: {
-							return false, propagateCommand, fmt.Errorf("unknown command")
-						}
-					}
-				}
-				case "REPLCONF": {
-					switch arguments[0] {
-						case "listening-port": {
-							response, err = ReplConf(clientConn, arguments[1])
-							if err != nil {
-								return false, propagateCommand, err
-							}
-						}
-						case "capa": {
-							response, err = ReplConf(clientConn, "")
-							if err != nil {
-								return false, propagateCommand, err
-							}
-						}
-						default: {
-							return false, propagateCommand, fmt.Errorf("unknown command")
-						}
-					}
-				}
-				case "PSYNC": {
-					response, err = Psync()
-					if err != nil {
-						return false, propagateCommand, err
-					}
+	write := command.HasFlag(FlagWrite)
+	if write {
+		writeMutex.Lock()
+		defer writeMutex.Unlock()
+	}
+	atomic.AddInt64(&statCommandsProcessed, 1)
+	// the database the command runs in, before a command such as SWAPDB or MOVE takes effect
+	db := c.Db
+	response, err := command.Handler(c, arguments)
+	if err != nil {
+		return false, ReplyError(c, err)
+	}
+	if write {
+		propagate(db, respMsg)
+	}
+	return true, reply(c, response)
+}
+
+// propagate appends a write command executed in database db to the append only file and sends it to the replicas,
+// writeMutex must be held.
+func propagate(db int, respMsg *resp.RespType) {
+	serializedCommand, err := resp.SerializeResp(PropagatedCommand(respMsg))
+	if err != nil {
+		fmt.Printf("Error serializing a command to propagate: %s\n", err.Error())
+		return
+	}
+	aof.Append(db, []byte(serializedCommand))
+	worker.PropagateCommand(db, []byte(serializedCommand))
+}
 
-					_, err = clientConn.Write([]byte(response))
-					if err != nil {
-						fmt.Println("Error while responding to client: ", err.Error())
-						return false, propagateCommand, err
-					}
+// resolveCommand finds the command (or subcommand) called by arrayElems and checks its arity.
+// It returns the command along with its arguments, which exclude the command and subcommand names.
+func resolveCommand(arrayElems []string) (*Command, []string, error) {
+	command := LookupCommand(arrayElems[0])
+	if command == nil {
+		return nil, nil, ErrUnknownCommand(arrayElems[0], arrayElems[1:])
+	}
+	if !command.CheckArity(len(arrayElems)) {
+		return nil, nil, ErrWrongArity(command.Name)
+	}
+	if len(command.Subcommands) == 0 || (len(arrayElems) == 1 && command.Handler != nil) {
+		return command, arrayElems[1:], nil
+	}
 
-					// TODO: We are sending an empty RDB to replica for now, in future this will be replaced by an RDB of the current state
-					emptyRdbBytes, _ := hex.DecodeString("524544495330303131fa0972656469732d76657205372e322e30fa0a72656469732d62697473c040fa056374696d65c26d08bc65fa08757365642d6d656dc2b0c41000fa08616f662d62617365c000fff06e3bfec0ff5aa2")
-					clientConn.Write(append([]byte(fmt.Sprintf("$%d\r\n", len(emptyRdbBytes))), emptyRdbBytes...))
-					if err != nil {
-						fmt.Println("Error while sendign RDB to client: ", err.Error())
-						return false, propagateCommand, err
-					}
-					return true, propagateCommand, nil 
-				}
-			}
-		}
-		default: {
-			return false,propagateCommand, fmt.Errorf("unknown command")
-		}
+	subcommand := command.Subcommands[strings.ToUpper(arrayElems[1])]
+	if subcommand == nil {
+		return nil, nil, ErrUnknownSubcommand(command.Name, arrayElems[1])
+	}
+	if !subcommand.CheckArity(len(arrayElems)) {
+		return nil, nil, ErrWrongArity(subcommand.Name)
 	}
+	return subcommand, arrayElems[2:], nil
+}
 
-	_, err = clientConn.Write([]byte(response))
+// ReplyError sends an error reply to the client.
+func ReplyError(c *client.Client, err error) error {
+	atomic.AddInt64(&statErrorReplies, 1)
+	response, err := errorReply(c, err)
 	if err != nil {
-		fmt.Println("Error while responding to client: ", err.Error())
-		return false, propagateCommand, nil
+		return err
 	}
+	return reply(c, response)
+}
 
-	return true, propagateCommand, nil
-}
\ No newline at end of file
+// reply appends a serialized reply to the output buffer of the client, commands received from our master are never
+// replied to.
+func reply(c *client.Client, response string) error {
+	if c.IsMaster || c.Conn == nil || response == "" {
+		return nil
+	}
+	return c.Write([]byte(response))
+}
+
+// commandArguments extracts the command name and its arguments out of a command sent by a client.
+func commandArguments(respMsg *resp.RespType) ([]string, error) {
+	if respMsg.DataType != resp.Array {
+		return nil, fmt.Errorf("Protocol error: expected a command as an array of bulk strings")
+	}
+
+	arrayElems := make([]string, 0, len(respMsg.Array))
+	for _, elem := range respMsg.Array {
+		if elem.DataType != resp.BulkString || elem.IsNull {
+			return nil, fmt.Errorf("Protocol error: expected a command as an array of bulk strings")
+		}
+		arrayElems = append(arrayElems, elem.String)
+	}
+	return arrayElems, nil
+}
@@ -1,36 +1,361 @@
 package commands
 
 import (
+	"fmt"
+	"math"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"memodb/internal/acl"
+	"memodb/internal/aof"
+	"memodb/internal/client"
+	"memodb/internal/config"
+	"memodb/internal/glob"
 	"memodb/internal/resp"
 	"memodb/internal/store"
 )
 
-func ConfigGet(key string) (string, error) {
-	val, isPresent := store.GetStore("/config/" + key)
-
-	if isPresent {
-		response, err := resp.SerializeResp(resp.RespType{
-			DataType: resp.Array,
-			Array: []*resp.RespType{
-				&resp.RespType{
-					DataType: resp.BulkString,
-					String: key,
-				},
-				&resp.RespType{
-					DataType: resp.BulkString,
-					String: val,
-				},
-			},
-		})
-		if err != nil {
-			return "", err
+func init() {
+	config.Register(
+		&config.Param{
+			Name: "port", Type: config.IntParam, Default: "6379", Min: 0, Max: 65535, Flags: config.FlagImmutable,
+			Description: "Port on which the server accepts connections",
+		},
+		&config.Param{
+			Name: "bind", Type: config.StringParam, Default: "127.0.0.1 -::1", Flags: config.FlagImmutable | config.FlagMultiArg,
+			Description: "IPv4 or IPv6 addresses on which the server accepts connections, * and ::* stand for every address and a leading - makes an address optional",
+			Validate: validateBind,
+		},
+		&config.Param{
+			Name: "unixsocket", Type: config.StringParam, Default: "", Flags: config.FlagImmutable,
+			Description: "Path of the unix socket on which the server also accepts connections, none when empty",
+		},
+		&config.Param{
+			Name: "unixsocketperm", Type: config.StringParam, Default: "0", Flags: config.FlagImmutable,
+			Description: "Octal permissions of the unix socket, 0 keeps the ones given by the umask",
+			Validate: validateSocketPerm,
+		},
+		&config.Param{
+			Name: "replica-announce-ip", Type: config.StringParam, Default: "", Flags: config.FlagImmutable,
+			Description: "IP address a replica announces to its master, instead of the one it connects from",
+			Validate: validateAnnounceIp,
+		},
+		&config.Param{
+			Name: "tls-port", Type: config.IntParam, Default: "0", Min: 0, Max: 65535, Flags: config.FlagImmutable,
+			Description: "Port on which the server accepts TLS connections, 0 disables TLS",
+		},
+		&config.Param{
+			Name: "tls-cert-file", Type: config.StringParam, Default: "", Flags: config.FlagImmutable,
+			Description: "Certificate of the server in PEM format, also presented to the master by replicas using TLS",
+		},
+		&config.Param{
+			Name: "tls-key-file", Type: config.StringParam, Default: "", Flags: config.FlagImmutable,
+			Description: "Private key of tls-cert-file in PEM format",
+		},
+		&config.Param{
+			Name: "tls-ca-cert-file", Type: config.StringParam, Default: "", Flags: config.FlagImmutable,
+			Description: "Certificates of the authorities trusted to sign the certificates of clients and of the master in PEM format",
+		},
+		&config.Param{
+			Name: "tls-auth-clients", Type: config.EnumParam, Default: "yes", Flags: config.FlagImmutable,
+			Values: []string{"yes", "no", "optional"},
+			Description: "Whether TLS clients must present a certificate signed by tls-ca-cert-file (yes, no or optional)",
+		},
+		&config.Param{
+			Name: "tls-replication", Type: config.BoolParam, Default: "no", Flags: config.FlagImmutable,
+			Description: "Whether a replica connects to the tls-port of its master (yes or no)",
+		},
+		&config.Param{
+			Name: "replicaof", Type: config.StringParam, Default: "", Flags: config.FlagImmutable | config.FlagMultiArg,
+			Description: "Host and port of the master as \"<host> <port>\", the server is a master when empty",
+			Validate: validateReplicaOf,
+		},
+		&config.Param{
+			Name: "requirepass", Type: config.StringParam, Default: "",
+			Description: "Password of the default user, clients authenticate with using AUTH, no authentication is required when empty",
+			Apply: func(val string) error {
+				acl.SetDefaultPassword(val)
+				return nil
+			},
+		},
+		&config.Param{
+			Name: "aclfile", Type: config.StringParam, Default: "", Flags: config.FlagImmutable,
+			Description: "Path of the file the ACL users are loaded from when the server starts and saved to by ACL SAVE",
+		},
+		&config.Param{
+			Name: "acllog-max-len", Type: config.IntParam, Default: "128", Min: 0, Max: math.MaxInt32,
+			Description: "Number of entries kept by the ACL log",
+			Apply: func(val string) error {
+				maxLen, _ := strconv.Atoi(val)
+				acl.SetLogMaxLen(maxLen)
+				return nil
+			},
+		},
+		&config.Param{
+			Name: "masterauth", Type: config.StringParam, Default: "",
+			Description: "Password a replica authenticates with against its master",
+		},
+		&config.Param{
+			Name: "masteruser", Type: config.StringParam, Default: "",
+			Description: "ACL user a replica authenticates as against its master, the default user when empty",
+		},
+		&config.Param{
+			Name: "maxclients", Type: config.IntParam, Default: "10000", Min: 1, Max: math.MaxInt32,
+			Description: "Maximum number of connected clients, further connections are refused",
+		},
+		&config.Param{
+			Name: "shutdown-timeout", Type: config.IntParam, Default: "10", Min: 0, Max: math.MaxInt32,
+			Description: "Seconds a shutdown waits for the replicas to receive the pending writes, 0 to not wait",
+		},
+		&config.Param{
+			Name: "timeout", Type: config.IntParam, Default: "0", Min: 0, Max: math.MaxInt32,
+			Description: "Seconds after which a client that sent no command is disconnected, 0 to never disconnect idle clients",
+			Apply: func(val string) error {
+				timeout, _ := strconv.Atoi(val)
+				for _, c := range client.Clients() {
+					c.SetIdleTimeout(time.Duration(timeout) * time.Second)
+				}
+				return nil
+			},
+		},
+		&config.Param{
+			Name: "tcp-keepalive", Type: config.IntParam, Default: "300", Min: 0, Max: math.MaxInt32,
+			Description: "Seconds between the TCP keepalive probes sent to clients to detect dead peers, 0 to disable them. Applies to new connections",
+		},
+		&config.Param{
+			Name: "databases", Type: config.IntParam, Default: "16", Min: 1, Max: math.MaxInt32, Flags: config.FlagImmutable,
+			Description: "Number of databases, clients select one of them with SELECT",
+		},
+		&config.Param{
+			Name: "dir", Type: config.StringParam, Default: ".",
+			Description: "Directory where the RDB dump and the append only file are stored",
+			Validate: validateDir,
+		},
+		&config.Param{
+			Name: "dbfilename", Type: config.StringParam, Default: "dump.rdb",
+			Description: "Name of the RDB dump",
+			Validate: validateFileName("dbfilename"),
+		},
+		&config.Param{
+			Name: "save", Type: config.StringParam, Default: "", Flags: config.FlagMultiArg | config.FlagAppend,
+			Description: "Save points as \"<seconds> <changes> [<seconds> <changes> ...]\", the dataset is saved when any of them is reached",
+			Validate: func(val string) error {
+				_, err := store.ParseSaveParams(val)
+				return err
+			},
+			Apply: func(val string) error {
+				params, err := store.ParseSaveParams(val)
+				if err != nil {
+					return err
+				}
+				store.SetSaveParams(params)
+				return nil
+			},
+		},
+		&config.Param{
+			Name: "maxmemory", Type: config.MemoryParam, Default: "0", Min: 0, Max: math.MaxInt64,
+			Description: "Memory limit of the dataset in bytes, 0 for no limit. No eviction policy is implemented, the limit is only reported",
+		},
+		&config.Param{
+			Name: "appendonly", Type: config.BoolParam, Default: "no",
+			Description: "Whether write commands are logged to the append only file (yes or no)",
+			Apply: func(val string) error {
+				if val == "yes" {
+					dirPath, fileName := AofLocation()
+					// no write may run between the snapshot and the start of appending
+					writeMutex.Lock()
+					defer writeMutex.Unlock()
+					return aof.Start(dirPath, fileName, store.Snapshot)
+				}
+				return aof.Stop()
+			},
+		},
+		&config.Param{
+			Name: "appendfilename", Type: config.StringParam, Default: "appendonly.aof", Flags: config.FlagImmutable,
+			Description: "Name of the append only file",
+			Validate: validateFileName("appendfilename"),
+		},
+		&config.Param{
+			Name: "appendfsync", Type: config.EnumParam, Default: aof.FsyncEverySec,
+			Values: []string{aof.FsyncAlways, aof.FsyncEverySec, aof.FsyncNo},
+			Description: "When the append only file is synced to disk (always, everysec or no)",
+			Apply: aof.SetFsyncPolicy,
+		},
+		&config.Param{
+			Name: "client-output-buffer-limit", Type: config.StringParam, Flags: config.FlagMultiArg | config.FlagAppend,
+			Default: "normal 0 0 0 replica 268435456 67108864 60 pubsub 33554432 8388608 60",
+			Description: "Output buffer limits as \"<class> <hard> <soft> <seconds>\" for the normal, replica and pubsub classes, clients over the hard limit or over the soft one for that many seconds are disconnected",
+			Normalize: func(val string) (string, error) {
+				current, _ := parseOutputBufferLimits(config.Get("client-output-buffer-limit"), nil)
+				limits, err := parseOutputBufferLimits(val, current)
+				if err != nil {
+					return "", err
+				}
+				return formatOutputBufferLimits(limits), nil
+			},
+			Apply: func(val string) error {
+				limits, err := parseOutputBufferLimits(val, nil)
+				if err != nil {
+					return err
+				}
+				client.SetOutputBufferLimits(limits)
+				return nil
+			},
+		},
+	)
+}
+
+// outputBufferLimitClasses holds the client classes of client-output-buffer-limit, in the order they are reported.
+var outputBufferLimitClasses = []string{"normal", "replica", "pubsub"}
+
+// parseOutputBufferLimits parses a client-output-buffer-limit value, made of "<class> <hard> <soft> <seconds>" groups,
+// on top of the limits in base. Like in Redis, slave is a synonym of the replica class.
+func parseOutputBufferLimits(val string, base map[string]client.OutputBufferLimit) (map[string]client.OutputBufferLimit, error) {
+	limits := map[string]client.OutputBufferLimit{}
+	for class, limit := range base {
+		limits[class] = limit
+	}
+	fields := strings.Fields(val)
+	if len(fields) % 4 != 0 {
+		return nil, fmt.Errorf("Wrong number of arguments in buffer limit configuration.")
+	}
+	for idx := 0; idx < len(fields); idx += 4 {
+		class := strings.ToLower(fields[idx])
+		if class == "slave" {
+			class = "replica"
+		}
+		if class != "normal" && class != "replica" && class != "pubsub" {
+			return nil, fmt.Errorf("Invalid client class specified in buffer limit configuration.")
+		}
+		hard, hardErr := config.ParseMemory(fields[idx + 1])
+		soft, softErr := config.ParseMemory(fields[idx + 2])
+		seconds, secondsErr := strconv.ParseInt(fields[idx + 3], 10, 64)
+		if hardErr != nil || softErr != nil || secondsErr != nil || seconds < 0 {
+			return nil, fmt.Errorf("Error in hard, soft or soft_seconds setting in buffer limit configuration.")
+		}
+		limits[class] = client.OutputBufferLimit{Hard: hard, Soft: soft, SoftSeconds: seconds}
+	}
+	return limits, nil
+}
+
+// formatOutputBufferLimits formats output buffer limits as a client-output-buffer-limit value holding every class.
+func formatOutputBufferLimits(limits map[string]client.OutputBufferLimit) string {
+	groups := []string{}
+	for _, class := range outputBufferLimitClasses {
+		limit := limits[class]
+		groups = append(groups, fmt.Sprintf("%s %d %d %d", class, limit.Hard, limit.Soft, limit.SoftSeconds))
+	}
+	return strings.Join(groups, " ")
+}
+
+// validateBind checks that a bind value lists IP addresses, * or ::*, each one optionally prefixed by '-'.
+func validateBind(val string) error {
+	for _, address := range strings.Fields(val) {
+		address = strings.TrimPrefix(address, "-")
+		if address != "*" && address != "::*" && net.ParseIP(address) == nil {
+			return fmt.Errorf("invalid bind address '%s'", address)
+		}
+	}
+	return nil
+}
+
+// validateSocketPerm checks that unix socket permissions are given in octal.
+func validateSocketPerm(val string) error {
+	if perm, err := strconv.ParseUint(val, 8, 32); err != nil || perm > 0777 {
+		return fmt.Errorf("argument must be an octal permission between 0 and 777")
+	}
+	return nil
+}
+
+// validateAnnounceIp checks that an announced address is a single word, it may be a host name.
+func validateAnnounceIp(val string) error {
+	if strings.ContainsAny(val, " \t\r\n") {
+		return fmt.Errorf("argument must be a single address")
+	}
+	return nil
+}
+
+// validateReplicaOf checks that a replicaof value is empty or made of a host and a port.
+func validateReplicaOf(val string) error {
+	fields := strings.Fields(val)
+	if len(fields) == 0 {
+		return nil
+	}
+	if len(fields) != 2 {
+		return fmt.Errorf("argument must be \"<host> <port>\"")
+	}
+	if port, err := strconv.Atoi(fields[1]); err != nil || port < 1 || port > 65535 {
+		return fmt.Errorf("invalid master port '%s'", fields[1])
+	}
+	return nil
+}
+
+// validateDir checks that a directory exists.
+func validateDir(val string) error {
+	info, err := os.Stat(val)
+	if err != nil {
+		return err
+	}
+	if !info.IsDir() {
+		return fmt.Errorf("%s is not a directory", val)
+	}
+	return nil
+}
+
+// validateFileName returns a function checking that the value of the parameter name is a file name rather than a path.
+func validateFileName(name string) func(val string) error {
+	return func(val string) error {
+		if val == "" || strings.ContainsRune(val, '/') {
+			return fmt.Errorf("%s can't be a path, just a filename", name)
 		}
-		return response, nil
-	} else {
-		return "$-1\r\n", nil
+		return nil
 	}
 }
 
-func ConfigSet(key, val string) {
-	store.SetStore("/config/" + key, val)
-}
\ No newline at end of file
+// ConfigGet function handles the CONFIG GET pattern [pattern ...] command by replying with every parameter matching
+// one of the glob-style patterns, case insensitively. The parameters are replied as a map to RESP3 clients and as a
+// flat array of names and values to RESP2 clients.
+func ConfigGet(c *client.Client, arguments []string) (string, error) {
+	pairs := []resp.RespType{}
+	for _, param := range config.Params() {
+		for _, pattern := range arguments {
+			if glob.Match(pattern, param.Name, true) {
+				pairs = append(pairs, resp.NewBulkString(param.Name), resp.NewBulkString(param.Value()))
+				break
+			}
+		}
+	}
+
+	return c.Serialize(resp.NewMap(pairs...))
+}
+
+// ConfigSet function handles the CONFIG SET parameter value [parameter value ...] command. Every value is validated
+// before any of them is applied, and none of them is kept when one fails to apply.
+func ConfigSet(c *client.Client, arguments []string) (string, error) {
+	if len(arguments) % 2 != 0 {
+		return "", ErrWrongArity("config|set")
+	}
+
+	if err := config.Set(arguments...); err != nil {
+		return "", err
+	}
+	return c.Serialize(resp.NewSimpleString("OK"))
+}
+
+// ConfigResetstat function handles the CONFIG RESETSTAT command by resetting the statistics reported by INFO.
+func ConfigResetstat(c *client.Client, arguments []string) (string, error) {
+	ResetStats()
+	return c.Serialize(resp.NewSimpleString("OK"))
+}
+
+// ConfigRewrite function handles the CONFIG REWRITE command by writing the current configuration to the config file
+// the server was started with.
+func ConfigRewrite(c *client.Client, arguments []string) (string, error) {
+	if err := config.Rewrite(); err != nil {
+		return "", err
+	}
+	return c.Serialize(resp.NewSimpleString("OK"))
+}
@@ -0,0 +1,109 @@
+package commands
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"memodb/internal/client"
+	"memodb/internal/resp"
+	"memodb/internal/store"
+)
+
+// Select function handles the SELECT index command by changing the database the client works on.
+func Select(c *client.Client, arguments []string) (string, error) {
+	db, err := parseDbIndex(arguments[0])
+	if err != nil {
+		return "", err
+	}
+
+	c.Update(func() { c.Db = db })
+	return c.Serialize(resp.NewSimpleString("OK"))
+}
+
+// Move function handles the MOVE key db command by moving a key of the selected database to another database. The
+// reply is 1 when the key was moved, and 0 when it does not exist or already exists in the target database.
+func Move(c *client.Client, arguments []string) (string, error) {
+	db, err := parseDbIndex(arguments[1])
+	if err != nil {
+		return "", err
+	}
+	if db == c.Db {
+		return "", fmt.Errorf("ERR source and destination objects are the same")
+	}
+
+	if store.MoveKey(c.Db, db, arguments[0]) {
+		return c.Serialize(resp.NewInteger(1))
+	}
+	return c.Serialize(resp.NewInteger(0))
+}
+
+// Swapdb function handles the SWAPDB index1 index2 command by swapping the keys of two databases.
+func Swapdb(c *client.Client, arguments []string) (string, error) {
+	db1, err := strconv.Atoi(arguments[0])
+	if err != nil {
+		return "", fmt.Errorf("ERR invalid first DB index")
+	}
+	db2, err := strconv.Atoi(arguments[1])
+	if err != nil {
+		return "", fmt.Errorf("ERR invalid second DB index")
+	}
+	if db1 < 0 || db1 >= store.DatabaseCount() || db2 < 0 || db2 >= store.DatabaseCount() {
+		return "", ErrDbIndexOutOfRange
+	}
+
+	store.SwapDb(db1, db2)
+	return c.Serialize(resp.NewSimpleString("OK"))
+}
+
+// Flushdb function handles the FLUSHDB [ASYNC | SYNC] command by removing every key of the selected database.
+func Flushdb(c *client.Client, arguments []string) (string, error) {
+	if err := parseFlushMode(arguments); err != nil {
+		return "", err
+	}
+
+	store.FlushDb(c.Db)
+	return c.Serialize(resp.NewSimpleString("OK"))
+}
+
+// Flushall function handles the FLUSHALL [ASYNC | SYNC] command by removing every key of every database.
+func Flushall(c *client.Client, arguments []string) (string, error) {
+	if err := parseFlushMode(arguments); err != nil {
+		return "", err
+	}
+
+	store.FlushAll()
+	return c.Serialize(resp.NewSimpleString("OK"))
+}
+
+// Dbsize function handles the DBSIZE command by replying with the number of keys of the selected database.
+func Dbsize(c *client.Client, arguments []string) (string, error) {
+	return c.Serialize(resp.NewInteger(store.DbSize(c.Db)))
+}
+
+// parseDbIndex parses a database number and checks that the database exists.
+func parseDbIndex(arg string) (int, error) {
+	db, err := strconv.Atoi(arg)
+	if err != nil {
+		return 0, ErrNotInteger
+	}
+	if db < 0 || db >= store.DatabaseCount() {
+		return 0, ErrDbIndexOutOfRange
+	}
+	return db, nil
+}
+
+// parseFlushMode validates the optional ASYNC or SYNC argument of the flush commands. Flushed keys are always
+// reclaimed in the background, so both modes behave the same.
+func parseFlushMode(arguments []string) error {
+	if len(arguments) > 1 {
+		return ErrSyntax
+	}
+	if len(arguments) == 1 {
+		mode := strings.ToUpper(arguments[0])
+		if mode != "ASYNC" && mode != "SYNC" {
+			return ErrSyntax
+		}
+	}
+	return nil
+}
@@ -0,0 +1,66 @@
+package commands
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"memodb/internal/client"
+	"memodb/internal/resp"
+)
+
+// Errors replied to clients, like in Redis every message starts with an upper case error code.
+// Errors that do not start with a code are replied with the generic ERR code.
+var (
+	ErrSyntax = errors.New("ERR syntax error")
+	ErrNotInteger = errors.New("ERR value is not an integer or out of range")
+	ErrWrongType = errors.New("WRONGTYPE Operation against a key holding the wrong kind of value")
+	ErrDbIndexOutOfRange = errors.New("ERR DB index is out of range")
+	ErrNoAuth = errors.New("NOAUTH Authentication required.")
+	ErrWrongPass = errors.New("WRONGPASS invalid username-password pair or user is disabled.")
+)
+
+// ErrUnknownCommand returns the error replied for a command that does not exist.
+func ErrUnknownCommand(command string, arguments []string) error {
+	argumentsPrefix := ""
+	for _, argument := range arguments {
+		if len(argumentsPrefix) >= 128 {
+			break
+		}
+		argumentsPrefix += fmt.Sprintf("'%s' ", argument)
+	}
+	return fmt.Errorf("ERR unknown command '%s', with args beginning with: %s", command, argumentsPrefix)
+}
+
+// ErrUnknownSubcommand returns the error replied for a subcommand that does not exist.
+func ErrUnknownSubcommand(command, subcommand string) error {
+	return fmt.Errorf("ERR unknown subcommand '%s'. Try %s HELP.", subcommand, strings.ToUpper(command))
+}
+
+// ErrWrongArity returns the error replied when a command is called with the wrong number of arguments.
+func ErrWrongArity(command string) error {
+	return fmt.Errorf("ERR wrong number of arguments for '%s' command", strings.ToLower(command))
+}
+
+// errorReply converts an error into a RESP error, prefixing it with the ERR code when it does not start with one.
+func errorReply(c *client.Client, err error) (string, error) {
+	msg := strings.NewReplacer("\r", " ", "\n", " ").Replace(err.Error())
+	if !hasErrorCode(msg) {
+		msg = "ERR " + msg
+	}
+	return c.Serialize(resp.NewError(msg))
+}
+
+// hasErrorCode checks if the first word of an error message is an upper case error code such as ERR or WRONGTYPE.
+func hasErrorCode(msg string) bool {
+	code, _, found := strings.Cut(msg, " ")
+	if !found || code == "" {
+		return false
+	}
+	for _, char := range code {
+		if char < 'A' || char > 'Z' {
+			return false
+		}
+	}
+	return true
+}
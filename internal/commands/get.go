@@ -1,26 +1,23 @@
 package commands
 
 import (
+	"memodb/internal/client"
 	"memodb/internal/resp"
 	"memodb/internal/store"
+	"memodb/internal/store/rdb"
 )
 
-func Get(arguments []string) (string, error) {
+// Get function handles the GET key command.
+func Get(c *client.Client, arguments []string) (string, error) {
 	key := arguments[0]
-	val, isPresent := store.GetStore(key)
+	if valueType, isPresent := store.GetType(c.Db, key); isPresent && valueType != rdb.StringType {
+		return "", ErrWrongType
+	}
+	val, isPresent := store.GetStore(c.Db, key)
 
 	if isPresent {
-		response, err := resp.SerializeResp(resp.RespType{
-			DataType: resp.BulkString,
-			String: val,
-		})
-		if err != nil {
-			return "", err
-		}
-		return response, nil
+		return c.Serialize(resp.NewBulkString(val))
 	} else {
-		return "$-1\r\n", nil
+		return c.Serialize(resp.NewNullBulkString())
 	}
-
-	
-}
\ No newline at end of file
+}
@@ -0,0 +1,91 @@
+package commands
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"memodb/internal/client"
+	"memodb/internal/resp"
+	"memodb/internal/worker"
+)
+
+const (
+	// serverName and serverVersion are reported to clients as those of the Redis release MemoDB is compatible with
+	serverName = "redis"
+	serverVersion = "7.2.0"
+)
+
+// Hello function handles the HELLO [protover [AUTH username password] [SETNAME clientname]] command by switching the
+// connection to the requested RESP version and replying with a map of server properties.
+func Hello(c *client.Client, arguments []string) (string, error) {
+	protocol := c.Protocol
+	clientName := c.Name
+	authenticated := false
+	if len(arguments) > 0 {
+		version, err := strconv.Atoi(arguments[0])
+		if err != nil {
+			return "", fmt.Errorf("ERR Protocol version is not an integer or out of range")
+		}
+		if version != 2 && version != 3 {
+			return "", fmt.Errorf("NOPROTO unsupported protocol version")
+		}
+		protocol = version
+
+		for idx := 1; idx < len(arguments); idx++ {
+			option := strings.ToUpper(arguments[idx])
+			switch {
+				case option == "AUTH" && idx + 2 < len(arguments): {
+					if err := authenticate(c, arguments[idx + 1], arguments[idx + 2]); err != nil {
+						return "", err
+					}
+					authenticated = true
+					idx += 2
+				}
+				case option == "SETNAME" && idx + 1 < len(arguments): {
+					if !isValidClientName(arguments[idx + 1]) {
+						return "", fmt.Errorf("ERR Client names cannot contain spaces, newlines or special characters.")
+					}
+					clientName = arguments[idx + 1]
+					idx += 1
+				}
+				default: {
+					return "", fmt.Errorf("ERR Syntax error in HELLO option '%s'", arguments[idx])
+				}
+			}
+		}
+	}
+
+	if !authenticated && AuthRequired(c) {
+		return "", fmt.Errorf("NOAUTH HELLO must be called with the client already authenticated, otherwise the HELLO <proto> AUTH <user> <pass> option can be used to authenticate the client and select the RESP protocol version at the same time")
+	}
+
+	c.Update(func() {
+		c.Name = clientName
+		c.Protocol = protocol
+	})
+
+	role := worker.GetWorkerDetails().Role
+	if role == "slave" {
+		role = "replica"
+	}
+	return c.Serialize(resp.NewMap(
+		resp.NewBulkString("server"), resp.NewBulkString(serverName),
+		resp.NewBulkString("version"), resp.NewBulkString(serverVersion),
+		resp.NewBulkString("proto"), resp.NewInteger(c.Protocol),
+		resp.NewBulkString("id"), resp.NewInteger(int(c.Id)),
+		resp.NewBulkString("mode"), resp.NewBulkString("standalone"),
+		resp.NewBulkString("role"), resp.NewBulkString(role),
+		resp.NewBulkString("modules"), resp.NewArray(),
+	))
+}
+
+// isValidClientName checks that a client name only contains printable characters other than space.
+func isValidClientName(name string) bool {
+	for _, char := range name {
+		if char < '!' || char > '~' {
+			return false
+		}
+	}
+	return true
+}
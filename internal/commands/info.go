@@ -2,14 +2,93 @@ package commands
 
 import (
 	"fmt"
+	"strings"
 
+	"memodb/internal/aof"
+	"memodb/internal/client"
 	"memodb/internal/resp"
+	"memodb/internal/store"
 	"memodb/internal/worker"
 )
 
-func InfoReplication() (string, error) {
-	return resp.SerializeResp(resp.RespType{
-		DataType: resp.BulkString,
-		String: fmt.Sprintf("role:%s\nmaster_replid:%s\nmaster_repl_offset:%d", worker.GetWorkerDetails().Role, worker.GetWorkerDetails().Id, 0),
-	})
-}
\ No newline at end of file
+// infoSections holds the sections reported by INFO, in the order they are reported when no section is asked for.
+var infoSections = []struct {
+	name string
+	title string
+	content func() string
+}{
+	{"clients", "Clients", InfoClients},
+	{"persistence", "Persistence", InfoPersistence},
+	{"stats", "Stats", InfoStats},
+	{"replication", "Replication", InfoReplication},
+	{"keyspace", "Keyspace", InfoKeyspace},
+}
+
+// Info function handles the INFO [section ...] command by replying with the requested sections of server information.
+// All the sections are reported when no section is given, unknown sections are ignored.
+func Info(c *client.Client, arguments []string) (string, error) {
+	requested := map[string]bool{}
+	for _, argument := range arguments {
+		requested[strings.ToLower(argument)] = true
+	}
+	allSections := len(arguments) == 0 || requested["default"] || requested["all"] || requested["everything"]
+
+	sections := []string{}
+	for _, section := range infoSections {
+		if allSections || requested[section.name] {
+			sections = append(sections, "# " + section.title + "\r\n" + section.content())
+		}
+	}
+
+	return c.Serialize(resp.NewVerbatimString("txt", strings.Join(sections, "\r\n")))
+}
+
+// InfoReplication reports the role of the server, and the address of every connected replica when it is a master.
+func InfoReplication() string {
+	var builder strings.Builder
+	builder.WriteString(fmt.Sprintf("role:%s\r\n", worker.GetWorkerDetails().Role))
+	if worker.GetWorkerDetails().Role == "master" {
+		slaves := worker.ConnectedSlaves()
+		builder.WriteString(fmt.Sprintf("connected_slaves:%d\r\n", len(slaves)))
+		for idx, slave := range slaves {
+			ip, port := slave.Address()
+			builder.WriteString(fmt.Sprintf("slave%d:ip=%s,port=%s,state=online,offset=0,lag=0\r\n", idx, ip, port))
+		}
+	}
+	builder.WriteString(fmt.Sprintf("master_replid:%s\r\nmaster_repl_offset:%d\r\n", worker.GetWorkerDetails().Id, 0))
+	return builder.String()
+}
+
+func InfoPersistence() string {
+	bgsaveInProgress := boolToInt(store.IsBackgroundSaveInProgress())
+	lastBgsaveStatus, lastBgsaveDuration := store.LastBackgroundSaveStatus()
+	aofEnabled := boolToInt(aof.Enabled())
+	aofRewriteInProgress, aofLastRewriteOk, aofLastWriteOk := aof.Status()
+	return fmt.Sprintf("loading:0\r\nrdb_changes_since_last_save:%d\r\nrdb_bgsave_in_progress:%d\r\nrdb_last_save_time:%d\r\nrdb_last_bgsave_status:%s\r\nrdb_last_bgsave_time_sec:%d\r\naof_enabled:%d\r\naof_rewrite_in_progress:%d\r\naof_last_bgrewrite_status:%s\r\naof_last_write_status:%s\r\n", store.Dirty(), bgsaveInProgress, store.LastSave(), lastBgsaveStatus, lastBgsaveDuration, aofEnabled, boolToInt(aofRewriteInProgress), okOrErr(aofLastRewriteOk), okOrErr(aofLastWriteOk))
+}
+
+// InfoKeyspace reports the number of keys and of keys with an expiry of every database that is not empty.
+func InfoKeyspace() string {
+	var builder strings.Builder
+	for db := 0; db < store.DatabaseCount(); db++ {
+		keys, expires := store.DbStats(db)
+		if keys > 0 {
+			builder.WriteString(fmt.Sprintf("db%d:keys=%d,expires=%d,avg_ttl=0\r\n", db, keys, expires))
+		}
+	}
+	return builder.String()
+}
+
+func boolToInt(boolean bool) int {
+	if boolean {
+		return 1
+	}
+	return 0
+}
+
+func okOrErr(ok bool) string {
+	if ok {
+		return "ok"
+	}
+	return "err"
+}
@@ -1,26 +1,26 @@
 package commands
 
 import (
-	"strings"
-
+	"memodb/internal/client"
+	"memodb/internal/glob"
 	"memodb/internal/resp"
 	"memodb/internal/store"
 )
 
-func Keys(pattern string) (string, error){
-	keys := store.GetKeys()
-	respKeysArr := []*resp.RespType{};
+// Keys function handles the KEYS pattern command by replying with every key of the selected database matching the
+// glob-style pattern.
+func Keys(c *client.Client, arguments []string) (string, error) {
+	pattern := arguments[0]
+	keys := store.GetKeys(c.Db)
+	if pattern == "*" {
+		return c.Serialize(resp.NewBulkStringArray(keys...))
+	}
+
+	matching := []string{}
 	for _, key := range keys {
-		if strings.Contains(key, "/config") {
-			continue
+		if glob.Match(pattern, key, false) {
+			matching = append(matching, key)
 		}
-		respKeysArr = append(respKeysArr, &resp.RespType{
-			DataType: resp.String,
-			String: key,
-		})
 	}
-	return resp.SerializeResp(resp.RespType{
-		DataType: resp.Array,
-		Array: respKeysArr,
-	})
-}
\ No newline at end of file
+	return c.Serialize(resp.NewBulkStringArray(matching...))
+}
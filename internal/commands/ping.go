@@ -1,17 +1,17 @@
 package commands
 
-import "memodb/internal/resp"
+import (
+	"memodb/internal/client"
+	"memodb/internal/resp"
+)
 
-// Ping function handles the PING command by responding with a PONG.
-func Ping() (string, error) {
-	response, err := resp.SerializeResp(resp.RespType{
-		DataType: resp.String,
-		String: "PONG",
-	})
-
-	if err != nil {
-		return "", err
+// Ping function handles the PING [message] command by responding with a PONG, or with the message when one is given.
+func Ping(c *client.Client, arguments []string) (string, error) {
+	if len(arguments) > 1 {
+		return "", ErrWrongArity("ping")
 	}
-
-	return response, nil
-}
\ No newline at end of file
+	if len(arguments) == 1 {
+		return c.Serialize(resp.NewBulkString(arguments[0]))
+	}
+	return c.Serialize(resp.NewSimpleString("PONG"))
+}
@@ -0,0 +1,25 @@
+package commands
+
+import (
+	"strconv"
+	"strings"
+	"time"
+
+	"memodb/internal/resp"
+)
+
+// PropagatedCommand returns the form in which a write command is sent to the replicas and to the append only file.
+// Relative expiries are converted to absolute unix times, so replaying the command later does not extend the life
+// of the key.
+func PropagatedCommand(respMsg *resp.RespType) resp.RespType {
+	arrayElems, err := commandArguments(respMsg)
+	if err != nil || len(arrayElems) < 3 || strings.ToUpper(arrayElems[0]) != "SET" {
+		return *respMsg
+	}
+
+	expireAt, err := parseSetExpiry(arrayElems[3:], time.Now())
+	if err != nil || expireAt == 0 {
+		return *respMsg
+	}
+	return resp.NewBulkStringArray("SET", arrayElems[1], arrayElems[2], "PXAT", strconv.FormatUint(expireAt, 10))
+}
@@ -1,21 +1,46 @@
 package commands
 
 import (
+	"bytes"
 	"fmt"
 
+	"memodb/internal/client"
 	"memodb/internal/resp"
+	"memodb/internal/store"
+	"memodb/internal/worker"
 )
 
+// Psync function handles the PSYNC replicationid offset command by starting a full resynchronization with the replica,
+// which receives an RDB snapshot of the current dataset.
+// The FULLRESYNC reply and the RDB snapshot are written to the replica directly, so nothing is left to reply.
+func Psync(c *client.Client, arguments []string) (string, error) {
+	c.Update(func() { c.IsReplica = true })
+	response, err := resp.SerializeResp(resp.NewSimpleString(fmt.Sprintf("FULLRESYNC %s %d", "abc", 0)))
+	if err != nil {
+		return "", err
+	}
+	if err := c.Flush(); err != nil {
+		return "", err
+	}
 
-func Psync() (string, error) {
-	response, err := resp.SerializeResp(resp.RespType{
-		DataType: resp.String,
-		String: fmt.Sprintf("FULLRESYNC %s %d", "abc", 0),
-	})
+	err = c.WriteAhead(func() ([]byte, error) {
+		// no write may run between the snapshot and the start of streaming, the commands executed from then on are
+		// buffered by the replica client and follow the snapshot
+		writeMutex.Lock()
+		worker.StartStreaming(c.Conn, c.Send)
+		databases := store.Snapshot()
+		writeMutex.Unlock()
 
+		// the snapshot is encoded in memory first, its length has to be sent before it
+		var snapshot bytes.Buffer
+		if err := store.WriteSnapshot(&snapshot, databases); err != nil {
+			return nil, err
+		}
+		return append([]byte(fmt.Sprintf("%s$%d\r\n", response, snapshot.Len())), snapshot.Bytes()...), nil
+	})
 	if err != nil {
 		return "", err
 	}
 
-	return response, nil
-}
\ No newline at end of file
+	return "", nil
+}
@@ -0,0 +1,482 @@
+package commands
+
+import (
+	"sort"
+	"strings"
+
+	"memodb/internal/client"
+)
+
+// CommandFlag describes a property of a command, flags are combined as a bit mask.
+type CommandFlag uint
+
+const (
+	// FlagWrite marks commands that may modify the keyspace, they are propagated to replicas.
+	FlagWrite CommandFlag = 1 << iota
+	// FlagReadonly marks commands that only read the keyspace.
+	FlagReadonly
+	// FlagDenyOOM marks commands that may grow memory usage.
+	FlagDenyOOM
+	// FlagAdmin marks administrative commands such as replication or configuration ones.
+	FlagAdmin
+	// FlagPubsub marks commands related to publish / subscribe.
+	FlagPubsub
+	// FlagNoscript marks commands that can not be called from scripts.
+	FlagNoscript
+	// FlagLoading marks commands allowed while the dataset is being loaded.
+	FlagLoading
+	// FlagStale marks commands allowed on a replica with stale data.
+	FlagStale
+	// FlagFast marks commands running in O(1) or O(log(N)) time.
+	FlagFast
+	// FlagNoAuth marks commands allowed before the connection is authenticated.
+	FlagNoAuth
+)
+
+// flagNames holds the names of the flags as reported by COMMAND INFO, in reporting order.
+var flagNames = []struct {
+	flag CommandFlag
+	name string
+}{
+	{FlagWrite, "write"},
+	{FlagReadonly, "readonly"},
+	{FlagDenyOOM, "denyoom"},
+	{FlagAdmin, "admin"},
+	{FlagPubsub, "pubsub"},
+	{FlagNoscript, "noscript"},
+	{FlagLoading, "loading"},
+	{FlagStale, "stale"},
+	{FlagFast, "fast"},
+	{FlagNoAuth, "no_auth"},
+}
+
+// CommandHandler executes a command, arguments exclude the command (and subcommand) name.
+// It returns the serialized reply, or an error to be replied to the client.
+type CommandHandler func(c *client.Client, arguments []string) (string, error)
+
+// Command describes a command understood by the server.
+type Command struct {
+	// Name is the lower case name of the command, subcommands are named container|subcommand.
+	Name string
+	// Arity is the number of arguments including the command (and subcommand) name, a negative arity is a minimum.
+	Arity int
+	Flags CommandFlag
+	// FirstKey, LastKey and KeyStep locate the keys in the arguments, a negative LastKey counts from the end.
+	FirstKey int
+	LastKey int
+	KeyStep int
+	// Summary, Since, Group and Complexity are reported by COMMAND DOCS.
+	Summary string
+	Since string
+	Group string
+	Complexity string
+	// Categories holds the ACL categories of the command beyond those implied by its flags and group, such as @dangerous.
+	Categories []string
+	// Handler executes the command, container commands only need one when they can be called without a subcommand.
+	Handler CommandHandler
+	// Subcommands holds the subcommands of a container command such as CONFIG, keyed by their upper case name.
+	Subcommands map[string]*Command
+}
+
+// commandTable holds every command understood by the server, keyed by their upper case name.
+var commandTable = map[string]*Command{}
+
+func init() {
+	registerCommands(
+		&Command{
+			Name: "ping", Arity: -1, Flags: FlagFast | FlagStale,
+			Summary: "Returns the server's liveliness response.", Since: "1.0.0", Group: "connection", Complexity: "O(1)",
+			Handler: Ping,
+		},
+		&Command{
+			Name: "echo", Arity: -2, Flags: FlagFast | FlagLoading | FlagStale,
+			Summary: "Returns the given string.", Since: "1.0.0", Group: "connection", Complexity: "O(1)",
+			Handler: Echo,
+		},
+		&Command{
+			Name: "set", Arity: -3, Flags: FlagWrite | FlagDenyOOM, FirstKey: 1, LastKey: 1, KeyStep: 1,
+			Summary: "Sets the string value of a key, ignoring its type. The key is created if it doesn't exist.", Since: "1.0.0", Group: "string", Complexity: "O(1)",
+			Handler: Set,
+		},
+		&Command{
+			Name: "get", Arity: 2, Flags: FlagReadonly | FlagFast, FirstKey: 1, LastKey: 1, KeyStep: 1,
+			Summary: "Returns the string value of a key.", Since: "1.0.0", Group: "string", Complexity: "O(1)",
+			Handler: Get,
+		},
+		&Command{
+			Name: "type", Arity: 2, Flags: FlagReadonly | FlagFast, FirstKey: 1, LastKey: 1, KeyStep: 1,
+			Summary: "Determines the type of value stored at a key.", Since: "1.0.0", Group: "generic", Complexity: "O(1)",
+			Handler: Type,
+		},
+		&Command{
+			Name: "move", Arity: 3, Flags: FlagWrite | FlagFast, FirstKey: 1, LastKey: 1, KeyStep: 1,
+			Summary: "Moves a key to another database.", Since: "1.0.0", Group: "generic", Complexity: "O(1)",
+			Handler: Move,
+		},
+		&Command{
+			Name: "keys", Arity: 2, Flags: FlagReadonly,
+			Summary: "Returns all key names that match a pattern.", Since: "1.0.0", Group: "generic", Complexity: "O(N) with N being the number of keys in the database",
+			Categories: []string{"@dangerous"},
+			Handler: Keys,
+		},
+		&Command{
+			Name: "config", Arity: -2,
+			Summary: "A container for server configuration commands.", Since: "2.0.0", Group: "server", Complexity: "Depends on subcommand.",
+			Subcommands: subcommands(
+				&Command{
+					Name: "config|get", Arity: -3, Flags: FlagAdmin | FlagNoscript | FlagLoading | FlagStale,
+					Summary: "Returns the effective values of configuration parameters.", Since: "2.0.0", Group: "server", Complexity: "O(N) when N is the number of configuration parameters provided",
+					Handler: ConfigGet,
+				},
+				&Command{
+					Name: "config|set", Arity: -4, Flags: FlagAdmin | FlagNoscript | FlagLoading | FlagStale,
+					Summary: "Sets configuration parameters in-flight.", Since: "2.0.0", Group: "server", Complexity: "O(N) when N is the number of configuration parameters provided",
+					Handler: ConfigSet,
+				},
+				&Command{
+					Name: "config|resetstat", Arity: 2, Flags: FlagAdmin | FlagNoscript | FlagLoading | FlagStale,
+					Summary: "Resets the server's statistics.", Since: "2.0.0", Group: "server", Complexity: "O(1)",
+					Handler: ConfigResetstat,
+				},
+				&Command{
+					Name: "config|rewrite", Arity: 2, Flags: FlagAdmin | FlagNoscript | FlagLoading | FlagStale,
+					Summary: "Persists the effective configuration to file.", Since: "2.8.0", Group: "server", Complexity: "O(1)",
+					Handler: ConfigRewrite,
+				},
+			),
+		},
+		&Command{
+			Name: "acl", Arity: -2,
+			Summary: "A container for Access List Control commands.", Since: "6.0.0", Group: "server", Complexity: "Depends on subcommand.",
+			Subcommands: subcommands(
+				&Command{
+					Name: "acl|cat", Arity: -2, Flags: FlagNoscript | FlagLoading | FlagStale,
+					Summary: "Lists the ACL categories, or the commands inside a category.", Since: "6.0.0", Group: "server", Complexity: "O(1) since the categories and commands are a fixed set.",
+					Handler: AclCat,
+				},
+				&Command{
+					Name: "acl|deluser", Arity: -3, Flags: FlagAdmin | FlagNoscript | FlagLoading | FlagStale,
+					Summary: "Deletes ACL users, and terminates their connections.", Since: "6.0.0", Group: "server", Complexity: "O(1) amortized time considering the typical user.",
+					Handler: AclDeluser,
+				},
+				&Command{
+					Name: "acl|getuser", Arity: 3, Flags: FlagAdmin | FlagNoscript | FlagLoading | FlagStale,
+					Summary: "Lists the ACL rules of a user.", Since: "6.0.0", Group: "server", Complexity: "O(N). Where N is the number of password, command and pattern rules that the user has.",
+					Handler: AclGetuser,
+				},
+				&Command{
+					Name: "acl|list", Arity: 2, Flags: FlagAdmin | FlagNoscript | FlagLoading | FlagStale,
+					Summary: "Dumps the effective rules in ACL file format.", Since: "6.0.0", Group: "server", Complexity: "O(N). Where N is the number of configured users.",
+					Handler: AclList,
+				},
+				&Command{
+					Name: "acl|load", Arity: 2, Flags: FlagAdmin | FlagNoscript | FlagLoading | FlagStale,
+					Summary: "Reloads the rules from the configured ACL file.", Since: "6.0.0", Group: "server", Complexity: "O(N). Where N is the number of configured users.",
+					Handler: AclLoad,
+				},
+				&Command{
+					Name: "acl|log", Arity: -2, Flags: FlagAdmin | FlagNoscript | FlagLoading | FlagStale,
+					Summary: "Lists recent security events generated due to ACL rules.", Since: "6.0.0", Group: "server", Complexity: "O(N) with N being the number of entries shown.",
+					Handler: AclLog,
+				},
+				&Command{
+					Name: "acl|save", Arity: 2, Flags: FlagAdmin | FlagNoscript | FlagLoading | FlagStale,
+					Summary: "Saves the effective ACL rules in the configured ACL file.", Since: "6.0.0", Group: "server", Complexity: "O(N). Where N is the number of configured users.",
+					Handler: AclSave,
+				},
+				&Command{
+					Name: "acl|setuser", Arity: -3, Flags: FlagAdmin | FlagNoscript | FlagLoading | FlagStale,
+					Summary: "Creates and modifies an ACL user and its rules.", Since: "6.0.0", Group: "server", Complexity: "O(N). Where N is the number of rules provided.",
+					Handler: AclSetuser,
+				},
+				&Command{
+					Name: "acl|users", Arity: 2, Flags: FlagAdmin | FlagNoscript | FlagLoading | FlagStale,
+					Summary: "Lists all ACL users.", Since: "6.0.0", Group: "server", Complexity: "O(N). Where N is the number of configured users.",
+					Handler: AclUsers,
+				},
+				&Command{
+					Name: "acl|whoami", Arity: 2, Flags: FlagNoscript | FlagLoading | FlagStale,
+					Summary: "Returns the authenticated username of the current connection.", Since: "6.0.0", Group: "server", Complexity: "O(1)",
+					Handler: AclWhoami,
+				},
+			),
+		},
+		&Command{
+			Name: "client", Arity: -2,
+			Summary: "A container for client connection commands.", Since: "2.4.0", Group: "connection", Complexity: "Depends on subcommand.",
+			Subcommands: subcommands(
+				&Command{
+					Name: "client|getname", Arity: 2, Flags: FlagNoscript | FlagLoading | FlagStale,
+					Summary: "Returns the name of the connection.", Since: "2.6.9", Group: "connection", Complexity: "O(1)",
+					Handler: ClientGetname,
+				},
+				&Command{
+					Name: "client|id", Arity: 2, Flags: FlagNoscript | FlagLoading | FlagStale,
+					Summary: "Returns the unique client ID of the connection.", Since: "5.0.0", Group: "connection", Complexity: "O(1)",
+					Handler: ClientId,
+				},
+				&Command{
+					Name: "client|info", Arity: 2, Flags: FlagNoscript | FlagLoading | FlagStale,
+					Summary: "Returns information about the connection.", Since: "6.2.0", Group: "connection", Complexity: "O(1)",
+					Handler: ClientInfo,
+				},
+				&Command{
+					Name: "client|kill", Arity: -3, Flags: FlagAdmin | FlagNoscript | FlagLoading | FlagStale,
+					Summary: "Terminates open connections.", Since: "2.4.0", Group: "connection", Complexity: "O(N) where N is the number of client connections",
+					Handler: ClientKill,
+				},
+				&Command{
+					Name: "client|list", Arity: -2, Flags: FlagAdmin | FlagNoscript | FlagLoading | FlagStale,
+					Summary: "Lists open connections.", Since: "2.4.0", Group: "connection", Complexity: "O(N) where N is the number of client connections",
+					Handler: ClientList,
+				},
+				&Command{
+					Name: "client|no-evict", Arity: 3, Flags: FlagAdmin | FlagNoscript | FlagLoading | FlagStale,
+					Summary: "Sets the client eviction mode of the connection.", Since: "7.0.0", Group: "connection", Complexity: "O(1)",
+					Handler: ClientNoEvict,
+				},
+				&Command{
+					Name: "client|pause", Arity: -3, Flags: FlagAdmin | FlagNoscript | FlagLoading | FlagStale,
+					Summary: "Suspends commands processing.", Since: "3.0.0", Group: "connection", Complexity: "O(1)",
+					Handler: ClientPause,
+				},
+				&Command{
+					Name: "client|setname", Arity: 3, Flags: FlagNoscript | FlagLoading | FlagStale,
+					Summary: "Sets the connection name.", Since: "2.6.9", Group: "connection", Complexity: "O(1)",
+					Handler: ClientSetname,
+				},
+				&Command{
+					Name: "client|unpause", Arity: 2, Flags: FlagAdmin | FlagNoscript | FlagLoading | FlagStale,
+					Summary: "Resumes processing commands from paused clients.", Since: "6.2.0", Group: "connection", Complexity: "O(N) Where N is the number of paused clients",
+					Handler: ClientUnpause,
+				},
+			),
+		},
+		&Command{
+			Name: "info", Arity: -1, Flags: FlagLoading | FlagStale,
+			Summary: "Returns information and statistics about the server.", Since: "1.0.0", Group: "server", Complexity: "O(1)",
+			Categories: []string{"@dangerous"},
+			Handler: Info,
+		},
+		&Command{
+			Name: "hello", Arity: -1, Flags: FlagNoscript | FlagFast | FlagLoading | FlagStale | FlagNoAuth,
+			Summary: "Handshakes with the Redis server.", Since: "6.0.0", Group: "connection", Complexity: "O(1)",
+			Handler: Hello,
+		},
+		&Command{
+			Name: "auth", Arity: -2, Flags: FlagNoscript | FlagLoading | FlagStale | FlagFast | FlagNoAuth,
+			Summary: "Authenticates the connection.", Since: "1.0.0", Group: "connection", Complexity: "O(N) where N is the number of passwords defined for the user",
+			Handler: Auth,
+		},
+		&Command{
+			Name: "quit", Arity: -1, Flags: FlagNoscript | FlagLoading | FlagStale | FlagFast | FlagNoAuth,
+			Summary: "Closes the connection.", Since: "1.0.0", Group: "connection", Complexity: "O(1)",
+			Handler: Quit,
+		},
+		&Command{
+			Name: "replconf", Arity: -1, Flags: FlagAdmin | FlagNoscript | FlagLoading | FlagStale,
+			Summary: "An internal command for configuring the replication stream.", Since: "3.0.0", Group: "server", Complexity: "O(1)",
+			Handler: ReplConf,
+		},
+		&Command{
+			Name: "psync", Arity: -3, Flags: FlagAdmin | FlagNoscript,
+			Summary: "An internal command used in replication.", Since: "2.8.0", Group: "server", Complexity: "O(1)",
+			Handler: Psync,
+		},
+		&Command{
+			Name: "save", Arity: 1, Flags: FlagAdmin | FlagNoscript,
+			Summary: "Synchronously saves the database(s) to disk.", Since: "1.0.0", Group: "server", Complexity: "O(N) where N is the total number of keys in all databases",
+			Handler: Save,
+		},
+		&Command{
+			Name: "bgsave", Arity: -1, Flags: FlagAdmin | FlagNoscript,
+			Summary: "Asynchronously saves the database(s) to disk.", Since: "1.0.0", Group: "server", Complexity: "O(1)",
+			Handler: Bgsave,
+		},
+		&Command{
+			Name: "bgrewriteaof", Arity: 1, Flags: FlagAdmin | FlagNoscript,
+			Summary: "Asynchronously rewrites the append-only file to disk.", Since: "1.0.0", Group: "server", Complexity: "O(1)",
+			Handler: Bgrewriteaof,
+		},
+		&Command{
+			Name: "shutdown", Arity: -1, Flags: FlagAdmin | FlagNoscript | FlagLoading | FlagStale,
+			Summary: "Synchronously saves the database(s) to disk and shuts down the Redis server.", Since: "1.0.0", Group: "server", Complexity: "O(N) when saving, where N is the total number of keys in all databases when saving data, otherwise O(1)",
+			Handler: Shutdown,
+		},
+		&Command{
+			Name: "select", Arity: 2, Flags: FlagLoading | FlagStale | FlagFast,
+			Summary: "Changes the selected database.", Since: "1.0.0", Group: "connection", Complexity: "O(1)",
+			Handler: Select,
+		},
+		&Command{
+			Name: "swapdb", Arity: 3, Flags: FlagWrite | FlagFast,
+			Summary: "Swaps two Redis databases.", Since: "4.0.0", Group: "server", Complexity: "O(N) where N is the count of clients watching or blocking on keys from both databases.",
+			Categories: []string{"@keyspace", "@dangerous"},
+			Handler: Swapdb,
+		},
+		&Command{
+			Name: "flushdb", Arity: -1, Flags: FlagWrite,
+			Summary: "Remove all keys from the current database.", Since: "1.0.0", Group: "server", Complexity: "O(N) where N is the number of keys in the selected database",
+			Categories: []string{"@keyspace", "@dangerous"},
+			Handler: Flushdb,
+		},
+		&Command{
+			Name: "flushall", Arity: -1, Flags: FlagWrite,
+			Summary: "Removes all keys from all databases.", Since: "1.0.0", Group: "server", Complexity: "O(N) where N is the total number of keys in all databases",
+			Categories: []string{"@keyspace", "@dangerous"},
+			Handler: Flushall,
+		},
+		&Command{
+			Name: "dbsize", Arity: 1, Flags: FlagReadonly | FlagFast,
+			Summary: "Returns the number of keys in the database.", Since: "1.0.0", Group: "server", Complexity: "O(1)",
+			Categories: []string{"@keyspace"},
+			Handler: Dbsize,
+		},
+		&Command{
+			Name: "lastsave", Arity: 1, Flags: FlagLoading | FlagStale | FlagFast,
+			Summary: "Returns the Unix timestamp of the last successful save to disk.", Since: "1.0.0", Group: "server", Complexity: "O(1)",
+			Categories: []string{"@admin", "@dangerous"},
+			Handler: Lastsave,
+		},
+		&Command{
+			Name: "command", Arity: -1, Flags: FlagLoading | FlagStale,
+			Summary: "Returns detailed information about all commands.", Since: "2.8.13", Group: "server", Complexity: "O(N) where N is the total number of Redis commands",
+			Categories: []string{"@connection"},
+			Handler: CommandList,
+			Subcommands: subcommands(
+				&Command{
+					Name: "command|count", Arity: 2, Flags: FlagLoading | FlagStale,
+					Summary: "Returns a count of commands.", Since: "2.8.13", Group: "server", Complexity: "O(1)",
+					Handler: CommandCount,
+				},
+				&Command{
+					Name: "command|info", Arity: -2, Flags: FlagLoading | FlagStale,
+					Summary: "Returns information about one, multiple or all commands.", Since: "2.8.13", Group: "server", Complexity: "O(N) where N is the number of commands to look up",
+					Handler: CommandInfo,
+				},
+				&Command{
+					Name: "command|docs", Arity: -2, Flags: FlagLoading | FlagStale,
+					Summary: "Returns documentary information about one, multiple or all commands.", Since: "7.0.0", Group: "server", Complexity: "O(N) where N is the number of commands to look up",
+					Handler: CommandDocs,
+				},
+			),
+		},
+	)
+}
+
+// registerCommands adds commands to the command table.
+func registerCommands(commands ...*Command) {
+	for _, command := range commands {
+		commandTable[strings.ToUpper(command.Name)] = command
+	}
+}
+
+// subcommands builds the subcommand table of a container command.
+func subcommands(commands ...*Command) map[string]*Command {
+	table := map[string]*Command{}
+	for _, command := range commands {
+		_, subcommandName, _ := strings.Cut(command.Name, "|")
+		table[strings.ToUpper(subcommandName)] = command
+	}
+	return table
+}
+
+// LookupCommand returns the command with the given (case insensitive) name, or nil if there is no such command.
+func LookupCommand(name string) *Command {
+	return commandTable[strings.ToUpper(name)]
+}
+
+// AllCommands returns every top level command sorted by name.
+func AllCommands() []*Command {
+	commands := make([]*Command, 0, len(commandTable))
+	for _, command := range commandTable {
+		commands = append(commands, command)
+	}
+	sort.Slice(commands, func(i, j int) bool {
+		return commands[i].Name < commands[j].Name
+	})
+	return commands
+}
+
+// SortedSubcommands returns the subcommands of a container command sorted by name.
+func (command *Command) SortedSubcommands() []*Command {
+	subcommands := make([]*Command, 0, len(command.Subcommands))
+	for _, subcommand := range command.Subcommands {
+		subcommands = append(subcommands, subcommand)
+	}
+	sort.Slice(subcommands, func(i, j int) bool {
+		return subcommands[i].Name < subcommands[j].Name
+	})
+	return subcommands
+}
+
+// HasFlag checks if the command has the given flag.
+func (command *Command) HasFlag(flag CommandFlag) bool {
+	return command.Flags & flag != 0
+}
+
+// CheckArity checks if argc, the number of arguments including the command name, is accepted by the command.
+func (command *Command) CheckArity(argc int) bool {
+	return (command.Arity > 0 && argc == command.Arity) || (command.Arity < 0 && argc >= -command.Arity)
+}
+
+// FlagNames returns the names of the flags of the command.
+func (command *Command) FlagNames() []string {
+	names := []string{}
+	for _, flagName := range flagNames {
+		if command.HasFlag(flagName.flag) {
+			names = append(names, flagName.name)
+		}
+	}
+	return names
+}
+
+// groupCategories holds the ACL category implied by the group of a command.
+var groupCategories = map[string]string{
+	"generic": "@keyspace",
+	"string": "@string",
+	"connection": "@connection",
+}
+
+// AclCategories returns the ACL categories of the command, those implied by its flags and group followed by its
+// Categories.
+func (command *Command) AclCategories() []string {
+	categories := []string{}
+	if category, isPresent := groupCategories[command.Group]; isPresent {
+		categories = append(categories, category)
+	}
+	if command.HasFlag(FlagWrite) {
+		categories = append(categories, "@write")
+	}
+	if command.HasFlag(FlagReadonly) {
+		categories = append(categories, "@read")
+	}
+	if command.HasFlag(FlagAdmin) {
+		categories = append(categories, "@admin", "@dangerous")
+	}
+	if command.HasFlag(FlagPubsub) {
+		categories = append(categories, "@pubsub")
+	}
+	if command.HasFlag(FlagFast) {
+		categories = append(categories, "@fast")
+	} else {
+		categories = append(categories, "@slow")
+	}
+	return append(categories, command.Categories...)
+}
+
+// KeyPositions returns the indexes of the keys in the arguments of a call, argv includes the command name.
+func (command *Command) KeyPositions(argv []string) []int {
+	positions := []int{}
+	if command.FirstKey <= 0 || command.KeyStep <= 0 {
+		return positions
+	}
+	lastKey := command.LastKey
+	if lastKey < 0 {
+		lastKey = len(argv) + lastKey
+	}
+	for idx := command.FirstKey; idx <= lastKey && idx < len(argv); idx += command.KeyStep {
+		positions = append(positions, idx)
+	}
+	return positions
+}
@@ -1,27 +1,36 @@
 package commands
 
 import (
-	"net"
+	"fmt"
+	"strings"
 
+	"memodb/internal/client"
 	"memodb/internal/resp"
 	"memodb/internal/worker"
 )
 
-
-func ReplConf(clientConn net.Conn, slavePort string) (string, error) {
-	if slavePort != "" {
-		worker.UpdateSlaveDetailsForMaster(clientConn, slavePort)
+// ReplConf function handles the REPLCONF option value [option value ...] command sent by replicas during the handshake.
+func ReplConf(c *client.Client, arguments []string) (string, error) {
+	if len(arguments) % 2 != 0 {
+		return "", ErrSyntax
 	}
-	
-	
-	response, err := resp.SerializeResp(resp.RespType{
-		DataType: resp.String,
-		String: "OK",
-	})
 
-	if err != nil {
-		return "", err
+	for idx := 0; idx < len(arguments); idx += 2 {
+		switch strings.ToLower(arguments[idx]) {
+			case "listening-port": {
+				worker.UpdateSlaveDetailsForMaster(c.Conn, arguments[idx + 1])
+			}
+			case "ip-address": {
+				worker.UpdateSlaveAddress(c.Conn, arguments[idx + 1])
+			}
+			case "capa": {
+				// capabilities are only acknowledged
+			}
+			default: {
+				return "", fmt.Errorf("ERR Unrecognized REPLCONF option: %s", arguments[idx])
+			}
+		}
 	}
 
-	return response, nil
-}
\ No newline at end of file
+	return resp.SerializeResp(resp.NewSimpleString("OK"))
+}
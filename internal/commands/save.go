@@ -0,0 +1,69 @@
+package commands
+
+import (
+	"strings"
+
+	"memodb/internal/aof"
+	"memodb/internal/client"
+	"memodb/internal/config"
+	"memodb/internal/resp"
+	"memodb/internal/store"
+)
+
+// Save function handles the SAVE command by synchronously writing the dataset to the configured RDB dump.
+func Save(c *client.Client, arguments []string) (string, error) {
+	dirPath, fileName := RdbLocation()
+	if err := store.SaveRdb(dirPath, fileName); err != nil {
+		return "", err
+	}
+	return c.Serialize(resp.NewSimpleString("OK"))
+}
+
+// Bgsave function handles the BGSAVE [SCHEDULE] command by writing the dataset to the configured RDB dump in the
+// background. With SCHEDULE, a save requested while another one is in progress runs once that one completes.
+func Bgsave(c *client.Client, arguments []string) (string, error) {
+	schedule := false
+	if len(arguments) > 1 || (len(arguments) == 1 && strings.ToUpper(arguments[0]) != "SCHEDULE") {
+		return "", ErrSyntax
+	}
+	if len(arguments) == 1 {
+		schedule = true
+	}
+
+	dirPath, fileName := RdbLocation()
+	scheduled, err := store.BackgroundSaveRdb(dirPath, fileName, schedule)
+	if err != nil {
+		return "", err
+	}
+	if scheduled {
+		return c.Serialize(resp.NewSimpleString("Background saving scheduled"))
+	}
+	return c.Serialize(resp.NewSimpleString("Background saving started"))
+}
+
+// Lastsave function handles the LASTSAVE command by replying with the unix time of the last successful save.
+func Lastsave(c *client.Client, arguments []string) (string, error) {
+	return c.Serialize(resp.NewInteger(int(store.LastSave())))
+}
+
+// RdbLocation returns the configured directory and file name of the RDB dump.
+func RdbLocation() (string, string) {
+	return config.Get("dir"), config.Get("dbfilename")
+}
+
+// AofLocation returns the configured directory and file name of the append only file.
+func AofLocation() (string, string) {
+	return config.Get("dir"), config.Get("appendfilename")
+}
+
+// Bgrewriteaof function handles the BGREWRITEAOF command by rewriting the append only file in the background.
+func Bgrewriteaof(c *client.Client, arguments []string) (string, error) {
+	dirPath, fileName := AofLocation()
+	writeMutex.Lock()
+	err := aof.BackgroundRewrite(dirPath, fileName, store.Snapshot)
+	writeMutex.Unlock()
+	if err != nil {
+		return "", err
+	}
+	return c.Serialize(resp.NewSimpleString("Background append only file rewriting started"))
+}
@@ -2,32 +2,68 @@ package commands
 
 import (
 	"fmt"
+	"math"
 	"strconv"
+	"strings"
+	"time"
 
+	"memodb/internal/client"
 	"memodb/internal/resp"
 	"memodb/internal/store"
 )
 
-func Set(arguments []string) (string, error) {
+// errInvalidExpire is replied when the expiry given to SET is not positive or out of range.
+var errInvalidExpire = fmt.Errorf("ERR invalid expire time in 'set' command")
+
+// Set function handles the SET key value [EX seconds | PX milliseconds | EXAT unix-time-seconds |
+// PXAT unix-time-milliseconds] command.
+func Set(c *client.Client, arguments []string) (string, error) {
 	key := arguments[0]
 	val := arguments[1]
-	if len(arguments) >= 4 {
-		expiryTimeInMilliSeconds, err := strconv.Atoi(arguments[3])
-		if err != nil {
-			return "", fmt.Errorf("expiry not a valid number in SET command")
-		}
-		store.SetStore(key, val, uint(expiryTimeInMilliSeconds))
-	} else {
-		store.SetStore(key, val)
-	}
 
-	response, err := resp.SerializeResp(resp.RespType{
-		DataType: resp.String,
-		String: "OK",
-	})
+	expireAt, err := parseSetExpiry(arguments[2:], time.Now())
 	if err != nil {
 		return "", err
 	}
 
-	return response, nil
-}
\ No newline at end of file
+	if expireAt > 0 {
+		store.SetStoreExpireAt(c.Db, key, val, expireAt)
+	} else {
+		store.SetStore(c.Db, key, val)
+	}
+
+	return c.Serialize(resp.NewSimpleString("OK"))
+}
+
+// parseSetExpiry parses the expiry options of SET and returns the unix time in milliseconds at which the key expires,
+// or 0 if it does not expire. Relative expiries are counted from now.
+func parseSetExpiry(options []string, now time.Time) (uint64, error) {
+	var expireAt uint64
+	for idx := 0; idx < len(options); idx++ {
+		option := strings.ToUpper(options[idx])
+		if (option != "EX" && option != "PX" && option != "EXAT" && option != "PXAT") || idx + 1 >= len(options) || expireAt != 0 {
+			return 0, ErrSyntax
+		}
+
+		expiry, err := strconv.ParseInt(options[idx + 1], 10, 64)
+		if err != nil {
+			return 0, ErrNotInteger
+		}
+		// the expiry is converted to milliseconds and made absolute, neither of which may overflow
+		if expiry <= 0 || ((option == "EX" || option == "EXAT") && expiry > math.MaxInt64 / 1000) {
+			return 0, errInvalidExpire
+		}
+		if option == "EX" || option == "EXAT" {
+			expiry *= 1000
+		}
+		if option == "EX" || option == "PX" {
+			if expiry > math.MaxInt64 - now.UnixMilli() {
+				return 0, errInvalidExpire
+			}
+			expiry += now.UnixMilli()
+		}
+		expireAt = uint64(expiry)
+		idx++
+	}
+	return expireAt, nil
+}
@@ -0,0 +1,251 @@
+package commands
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"memodb/internal/aof"
+	"memodb/internal/client"
+	"memodb/internal/config"
+	"memodb/internal/resp"
+	"memodb/internal/store"
+)
+
+// ShutdownOptions tells how the server shuts down, as given to SHUTDOWN.
+type ShutdownOptions struct {
+	// Save and NoSave force or prevent saving the RDB dump, which is otherwise saved when save points are configured.
+	Save bool
+	NoSave bool
+	// Now skips waiting for the replicas to catch up.
+	Now bool
+	// Force shuts the server down even when the dataset could not be persisted.
+	Force bool
+}
+
+// shutdownFlushTimeout is how long the clients are given to receive the replies buffered for them before the exit.
+const shutdownFlushTimeout = time.Second
+
+// errShutdown is replied when the server could not shut down and keeps running.
+var errShutdown = fmt.Errorf("ERR Errors trying to SHUTDOWN. Check logs.")
+
+var (
+	// shutdownMutex guards shutdownInProgress, shutdownAbort and shutdownHooks
+	shutdownMutex sync.Mutex
+	shutdownInProgress bool
+	// shutdownAbort is closed by SHUTDOWN ABORT, it is nil unless a shutdown is waiting for the replicas
+	shutdownAbort chan struct{}
+	// shutdownHooks run once the server is bound to exit, before the clients are disconnected
+	shutdownHooks []func()
+)
+
+// Shutdown function handles the SHUTDOWN [NOSAVE | SAVE] [NOW] [FORCE] [ABORT] command by shutting the server down, the
+// connection is closed without a reply. SHUTDOWN ABORT cancels a shutdown still waiting for the replicas.
+func Shutdown(c *client.Client, arguments []string) (string, error) {
+	options := ShutdownOptions{}
+	abort := false
+	for _, argument := range arguments {
+		switch strings.ToUpper(argument) {
+			case "NOSAVE": {
+				options.NoSave = true
+			}
+			case "SAVE": {
+				options.Save = true
+			}
+			case "NOW": {
+				options.Now = true
+			}
+			case "FORCE": {
+				options.Force = true
+			}
+			case "ABORT": {
+				abort = true
+			}
+			default: {
+				return "", ErrSyntax
+			}
+		}
+	}
+	if (options.Save && options.NoSave) || (abort && len(arguments) > 1) {
+		return "", ErrSyntax
+	}
+
+	if abort {
+		if !AbortShutdown() {
+			return "", fmt.Errorf("ERR No shutdown in progress.")
+		}
+		return c.Serialize(resp.NewSimpleString("OK"))
+	}
+	fmt.Println("User requested shutdown...")
+	// the server exits unless the shutdown fails
+	return "", ShutdownServer(options)
+}
+
+// OnShutdown registers a function to run once the server is bound to exit, such as closing the listeners.
+func OnShutdown(hook func()) {
+	shutdownMutex.Lock()
+	defer shutdownMutex.Unlock()
+	shutdownHooks = append(shutdownHooks, hook)
+}
+
+// ShutdownInProgress checks if the server is shutting down.
+func ShutdownInProgress() bool {
+	shutdownMutex.Lock()
+	defer shutdownMutex.Unlock()
+	return shutdownInProgress
+}
+
+// AbortShutdown cancels the shutdown waiting for the replicas, if any, and reports whether there was one.
+func AbortShutdown() bool {
+	shutdownMutex.Lock()
+	defer shutdownMutex.Unlock()
+	if shutdownAbort == nil {
+		return false
+	}
+	close(shutdownAbort)
+	shutdownAbort = nil
+	return true
+}
+
+/*
+	ShutdownServer shuts the server down, as done by SHUTDOWN and on SIGINT or SIGTERM. Write commands are held, the
+	replicas are given up to shutdown-timeout seconds to receive the pending writes, the append only file is synced
+	and the RDB dump is saved when configured. The listeners are then closed, the clients disconnected and the process
+	exits, with status 1 when FORCE let it exit despite an error. When the shutdown is aborted, or when the dataset
+	could not be persisted without FORCE, the server keeps running and an error is returned.
+
+	Function Signature:
+		func ShutdownServer(options ShutdownOptions) error
+
+	Parameters:
+		- options: How the server shuts down. (ShutdownOptions)
+
+	Returns:
+		- error - Error replied to SHUTDOWN when the server keeps running, it does not return otherwise.
+
+	Example Usage:
+		// the RDB dump can not be written to the dir
+		err := ShutdownServer(ShutdownOptions{Save: true})
+		// Output err = errShutdown, the server keeps running
+*/
+func ShutdownServer(options ShutdownOptions) error {
+	shutdownMutex.Lock()
+	if shutdownInProgress {
+		shutdownMutex.Unlock()
+		fmt.Println("Shutdown already in progress")
+		return errShutdown
+	}
+	shutdownInProgress = true
+	abort := make(chan struct{})
+	shutdownAbort = abort
+	shutdownMutex.Unlock()
+
+	// the dataset must not change while the replicas catch up and while it is persisted
+	pauseWritesForShutdown(true)
+	status, err := prepareShutdown(options, abort)
+	if err != nil {
+		pauseWritesForShutdown(false)
+		shutdownMutex.Lock()
+		shutdownInProgress = false
+		shutdownAbort = nil
+		shutdownMutex.Unlock()
+		return err
+	}
+
+	shutdownMutex.Lock()
+	hooks := shutdownHooks
+	shutdownMutex.Unlock()
+	for _, hook := range hooks {
+		hook()
+	}
+	// the replies to the commands pipelined before SHUTDOWN, by its own client too, are still buffered
+	var wg sync.WaitGroup
+	for _, other := range client.Clients() {
+		wg.Add(1)
+		go func(other *client.Client) {
+			defer wg.Done()
+			other.FlushAndKill(shutdownFlushTimeout)
+		}(other)
+	}
+	wg.Wait()
+	fmt.Println("MemoDB is now ready to exit, bye bye...")
+	os.Exit(status)
+	return nil
+}
+
+// prepareShutdown waits for the replicas and persists the dataset, it returns the exit status of the server or an
+// error when the server has to keep running.
+func prepareShutdown(options ShutdownOptions, abort chan struct{}) (int, error) {
+	timeout := time.Duration(config.GetInt("shutdown-timeout")) * time.Second
+	if !options.Now && timeout > 0 {
+		waitForReplicas(timeout, abort)
+	}
+	shutdownMutex.Lock()
+	aborted := shutdownAbort != abort
+	shutdownAbort = nil
+	shutdownMutex.Unlock()
+	if aborted {
+		fmt.Println("Shutdown aborted")
+		return 0, errShutdown
+	}
+
+	status := 0
+	if aof.Enabled() {
+		fmt.Println("Calling fsync() on the AOF file.")
+		if err := aof.Fsync(); err != nil {
+			fmt.Printf("Error syncing the append only file: %s\n", err.Error())
+			if !options.Force {
+				fmt.Println("Errors trying to shut down the server. Check the logs for more information.")
+				return 0, errShutdown
+			}
+			status = 1
+		}
+	}
+	if options.Save || (!options.NoSave && len(store.GetSaveParams()) > 0) {
+		fmt.Println("Saving the final RDB snapshot before exiting.")
+		if err := store.SaveRdbOnShutdown(RdbLocation()); err != nil {
+			fmt.Printf("Error trying to save the DB: %s\n", err.Error())
+			if !options.Force {
+				fmt.Println("Errors trying to shut down the server. Check the logs for more information.")
+				return 0, errShutdown
+			}
+			status = 1
+		} else {
+			fmt.Println("DB saved on disk")
+		}
+	}
+	return status, nil
+}
+
+// waitForReplicas waits until every replica received the commands queued for it, for at most timeout or until the
+// shutdown is aborted.
+func waitForReplicas(timeout time.Duration, abort chan struct{}) {
+	deadline := time.Now().Add(timeout)
+	logged := false
+	for {
+		lagging := []string{}
+		for _, other := range client.Clients() {
+			if other.Type() == "replica" && !other.OutputSent() {
+				lagging = append(lagging, other.Addr)
+			}
+		}
+		if len(lagging) == 0 {
+			return
+		}
+		if !logged {
+			fmt.Println("Waiting for replicas before shutting down.")
+			logged = true
+		}
+		if time.Now().After(deadline) {
+			fmt.Printf("Lagging replicas %s did not catch up before the shutdown timeout\n", strings.Join(lagging, ", "))
+			return
+		}
+		select {
+			case <-abort:
+				return
+			case <-time.After(10 * time.Millisecond):
+		}
+	}
+}
@@ -0,0 +1,65 @@
+package commands
+
+import (
+	"fmt"
+	"sync/atomic"
+
+	"memodb/internal/client"
+	"memodb/internal/config"
+)
+
+// statistics reported by the stats and clients sections of INFO, they are updated atomically and reset by CONFIG RESETSTAT
+var (
+	statConnectionsReceived int64
+	statCommandsProcessed int64
+	statErrorReplies int64
+	statRejectedConnections int64
+)
+
+var (
+	// openConnections counts the admitted connections that are not closed yet, it is checked against maxclients
+	openConnections int64
+	// blockedClients counts the clients whose command is held by CLIENT PAUSE
+	blockedClients int64
+)
+
+// AdmitConnection records a newly accepted connection, unless maxclients connections are already open. The connection
+// is then counted as rejected and false is returned.
+func AdmitConnection() bool {
+	if atomic.AddInt64(&openConnections, 1) > int64(config.GetInt("maxclients")) {
+		atomic.AddInt64(&openConnections, -1)
+		atomic.AddInt64(&statRejectedConnections, 1)
+		return false
+	}
+	return true
+}
+
+// ReleaseConnection records that an admitted connection is closed.
+func ReleaseConnection() {
+	atomic.AddInt64(&openConnections, -1)
+}
+
+// CountConnection records a connection accepted by the server.
+func CountConnection() {
+	atomic.AddInt64(&statConnectionsReceived, 1)
+}
+
+// ResetStats resets the statistics reported by the stats section of INFO.
+func ResetStats() {
+	atomic.StoreInt64(&statConnectionsReceived, 0)
+	atomic.StoreInt64(&statCommandsProcessed, 0)
+	atomic.StoreInt64(&statErrorReplies, 0)
+	atomic.StoreInt64(&statRejectedConnections, 0)
+}
+
+// InfoClients reports the number of connected clients, our master included, the maxclients limit, the number of
+// clients held by CLIENT PAUSE and the number of connections rejected because of maxclients.
+func InfoClients() string {
+	return fmt.Sprintf("connected_clients:%d\r\nmaxclients:%d\r\nblocked_clients:%d\r\nrejected_connections:%d\r\n", len(client.Clients()), config.GetInt("maxclients"), atomic.LoadInt64(&blockedClients), atomic.LoadInt64(&statRejectedConnections))
+}
+
+// InfoStats reports the number of connections accepted, commands processed and errors replied since the server
+// started or the statistics were reset.
+func InfoStats() string {
+	return fmt.Sprintf("total_connections_received:%d\r\ntotal_commands_processed:%d\r\ntotal_error_replies:%d\r\n", atomic.LoadInt64(&statConnectionsReceived), atomic.LoadInt64(&statCommandsProcessed), atomic.LoadInt64(&statErrorReplies))
+}
@@ -0,0 +1,16 @@
+package commands
+
+import (
+	"memodb/internal/client"
+	"memodb/internal/resp"
+	"memodb/internal/store"
+)
+
+// Type function handles the TYPE key command, a key that does not exist has the type none.
+func Type(c *client.Client, arguments []string) (string, error) {
+	valueType, isPresent := store.GetType(c.Db, arguments[0])
+	if !isPresent {
+		return c.Serialize(resp.NewSimpleString("none"))
+	}
+	return c.Serialize(resp.NewSimpleString(valueType.String()))
+}
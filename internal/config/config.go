@@ -0,0 +1,352 @@
+package config
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// ParamType is the type of the value of a configuration parameter.
+type ParamType int
+
+const (
+	// StringParam parameters accept any value their Validate function accepts.
+	StringParam ParamType = iota
+	// IntParam parameters accept integers between their Min and Max.
+	IntParam
+	// BoolParam parameters accept yes or no.
+	BoolParam
+	// EnumParam parameters accept one of their Values, case insensitively.
+	EnumParam
+	// MemoryParam parameters accept a number of bytes between their Min and Max, optionally followed by a unit such
+	// as kb, mb or gb.
+	MemoryParam
+)
+
+// ParamFlag describes a property of a configuration parameter, flags are combined as a bit mask.
+type ParamFlag uint
+
+const (
+	// FlagImmutable marks parameters that can only be set when the server starts.
+	FlagImmutable ParamFlag = 1 << iota
+	// FlagMultiArg marks parameters whose value is a list of words, written as separate arguments in the config file.
+	FlagMultiArg
+	// FlagAppend marks parameters whose values are collected when they are set on several lines of the config file.
+	FlagAppend
+)
+
+// Param describes a configuration parameter of the server.
+type Param struct {
+	// Name is the lower case name of the parameter, used by CONFIG GET, CONFIG SET and the command line.
+	Name string
+	Type ParamType
+	// Default is the value of the parameter until it is set.
+	Default string
+	Flags ParamFlag
+	// Description is shown by the command line help.
+	Description string
+	// Min and Max bound the value of integer and memory parameters.
+	Min int64
+	Max int64
+	// Values holds the accepted values of enum parameters.
+	Values []string
+	// Validate checks a value beyond what its type implies, it is nil when every value of the type is accepted.
+	Validate func(val string) error
+	// Normalize, when set, checks a value and returns its canonical form, such as a partial value completed with the
+	// current one.
+	Normalize func(val string) (string, error)
+	// Apply puts a value set with CONFIG SET in effect, it is nil when the value is only read when it is needed.
+	Apply func(val string) error
+
+	value string
+}
+
+var (
+	// mutex guards the values of the parameters
+	mutex sync.RWMutex
+	params = map[string]*Param{}
+	// setMutex makes sure changes made with CONFIG SET are applied one after the other
+	setMutex sync.Mutex
+	// loadedFromFile holds the parameters set by the config file or the files it includes, guarded by mutex
+	loadedFromFile = map[string]bool{}
+	// includedValues holds the values set by the included files, for parameters flagged FlagAppend the values they
+	// add, guarded by mutex
+	includedValues = map[string]string{}
+)
+
+// Register adds parameters to the registry, each one starts with its default value.
+func Register(newParams ...*Param) {
+	mutex.Lock()
+	defer mutex.Unlock()
+	for _, param := range newParams {
+		param.value = param.Default
+		params[param.Name] = param
+	}
+}
+
+// Lookup returns the parameter with the given (case insensitive) name, or nil if there is no such parameter.
+func Lookup(name string) *Param {
+	mutex.RLock()
+	defer mutex.RUnlock()
+	return params[strings.ToLower(name)]
+}
+
+// Params returns every parameter sorted by name.
+func Params() []*Param {
+	mutex.RLock()
+	defer mutex.RUnlock()
+	sorted := make([]*Param, 0, len(params))
+	for _, param := range params {
+		sorted = append(sorted, param)
+	}
+	sort.Slice(sorted, func(i, j int) bool {
+		return sorted[i].Name < sorted[j].Name
+	})
+	return sorted
+}
+
+// Get returns the value of a parameter, or an empty string if there is no such parameter.
+func Get(name string) string {
+	mutex.RLock()
+	defer mutex.RUnlock()
+	param, isPresent := params[name]
+	if !isPresent {
+		return ""
+	}
+	return param.value
+}
+
+// GetInt returns the value of an integer parameter.
+func GetInt(name string) int {
+	val, _ := strconv.Atoi(Get(name))
+	return val
+}
+
+// GetBool returns the value of a boolean parameter.
+func GetBool(name string) bool {
+	return Get(name) == "yes"
+}
+
+// Value returns the current value of the parameter.
+func (param *Param) Value() string {
+	return Get(param.Name)
+}
+
+// IsImmutable checks if the parameter can only be set when the server starts.
+func (param *Param) IsImmutable() bool {
+	return param.Flags & FlagImmutable != 0
+}
+
+// Check validates a value of the parameter and returns it in its canonical form, booleans and enum values are lower
+// cased, integers lose their leading zeros and memory values are converted to bytes.
+func (param *Param) Check(val string) (string, error) {
+	switch param.Type {
+		case IntParam: {
+			number, err := strconv.ParseInt(val, 10, 64)
+			if err != nil {
+				return "", fmt.Errorf("argument couldn't be parsed into an integer")
+			}
+			if number < param.Min || number > param.Max {
+				return "", fmt.Errorf("argument must be between %d and %d inclusive", param.Min, param.Max)
+			}
+			val = strconv.FormatInt(number, 10)
+		}
+		case MemoryParam: {
+			number, err := ParseMemory(val)
+			if err != nil {
+				return "", err
+			}
+			if number < param.Min || number > param.Max {
+				return "", fmt.Errorf("argument must be between %d and %d inclusive", param.Min, param.Max)
+			}
+			val = strconv.FormatInt(number, 10)
+		}
+		case BoolParam: {
+			val = strings.ToLower(val)
+			if val != "yes" && val != "no" {
+				return "", fmt.Errorf("argument must be 'yes' or 'no'")
+			}
+		}
+		case EnumParam: {
+			val = strings.ToLower(val)
+			accepted := false
+			for _, enumValue := range param.Values {
+				accepted = accepted || val == enumValue
+			}
+			if !accepted {
+				return "", fmt.Errorf("argument(s) must be one of the following: %s", strings.Join(param.Values, ", "))
+			}
+		}
+	}
+
+	if param.Validate != nil {
+		if err := param.Validate(val); err != nil {
+			return "", err
+		}
+	}
+	if param.Normalize != nil {
+		return param.Normalize(val)
+	}
+	return val, nil
+}
+
+// Load sets a parameter while the server starts, immutable parameters included. The value is not applied, the server
+// reads its configuration once every parameter is loaded.
+func Load(name, val string) error {
+	param := Lookup(name)
+	if param == nil {
+		return fmt.Errorf("unknown parameter '%s'", name)
+	}
+	val, err := param.Check(val)
+	if err != nil {
+		return err
+	}
+
+	mutex.Lock()
+	defer mutex.Unlock()
+	param.value = val
+	return nil
+}
+
+// markLoadedFromFile records that a parameter was set by the config file.
+func markLoadedFromFile(name string) {
+	mutex.Lock()
+	defer mutex.Unlock()
+	loadedFromFile[name] = true
+}
+
+// markIncluded records the value an included file sets a parameter to, or adds to it for parameters flagged FlagAppend.
+func markIncluded(param *Param, val string) {
+	mutex.Lock()
+	defer mutex.Unlock()
+	if previous := includedValues[param.Name]; param.Flags & FlagAppend != 0 && previous != "" && val != "" {
+		val = previous + " " + val
+	}
+	includedValues[param.Name] = val
+}
+
+// includedValue returns the value set by the included files for a parameter, or an empty string if they do not set it.
+func includedValue(name string) string {
+	mutex.RLock()
+	defer mutex.RUnlock()
+	return includedValues[name]
+}
+
+// isLoadedFromFile checks if a parameter was set by the config file or one of the files it includes.
+func isLoadedFromFile(name string) bool {
+	mutex.RLock()
+	defer mutex.RUnlock()
+	return loadedFromFile[name]
+}
+
+// memoryUnits holds the multipliers of the units understood by memory parameters, like in redis.conf k and kb differ.
+var memoryUnits = map[string]int64{
+	"": 1,
+	"b": 1,
+	"k": 1000,
+	"kb": 1024,
+	"m": 1000 * 1000,
+	"mb": 1024 * 1024,
+	"g": 1000 * 1000 * 1000,
+	"gb": 1024 * 1024 * 1024,
+}
+
+// ParseMemory parses an amount of memory such as 1024, 100mb or 2GB into a number of bytes.
+func ParseMemory(val string) (int64, error) {
+	lower := strings.ToLower(val)
+	digits := strings.TrimRight(lower, "bkmg")
+	multiplier, isUnit := memoryUnits[lower[len(digits):]]
+	number, err := strconv.ParseInt(digits, 10, 64)
+	if !isUnit || err != nil || number < 0 || (number > 0 && multiplier > math.MaxInt64 / number) {
+		return 0, fmt.Errorf("argument must be a memory value")
+	}
+	return number * multiplier, nil
+}
+
+/*
+	Set changes parameters while the server runs, as done by CONFIG SET. Every value is validated before any parameter
+	is changed, then the new values are applied in order. When one of them fails to apply, every parameter gets its
+	previous value back and the values applied so far are reverted.
+
+	Function Signature:
+		func Set(pairs ...string) error
+
+	Parameters:
+		- pairs: Names of the parameters, each one followed by its new value. (...string)
+
+	Returns:
+		- error - Error to be replied to the client, if any, else nil.
+
+	Example Usage:
+		err := Set("appendonly", "yes", "appendfsync", "always")
+		// Output err = nil
+		err := Set("databases", "32")
+		// Output err = "ERR CONFIG SET failed (possibly related to argument 'databases') - can't set immutable config"
+*/
+func Set(pairs ...string) error {
+	setMutex.Lock()
+	defer setMutex.Unlock()
+
+	type change struct {
+		argument string
+		param *Param
+		val string
+		previous string
+	}
+	changes := []change{}
+	seen := map[string]bool{}
+	for idx := 0; idx + 1 < len(pairs); idx += 2 {
+		param := Lookup(pairs[idx])
+		if param == nil {
+			return fmt.Errorf("ERR Unknown option or number of arguments for CONFIG SET - '%s'", pairs[idx])
+		}
+		if param.IsImmutable() {
+			return setFailed(pairs[idx], "can't set immutable config")
+		}
+		if seen[param.Name] {
+			return setFailed(pairs[idx], "duplicate parameter")
+		}
+		seen[param.Name] = true
+
+		val, err := param.Check(pairs[idx + 1])
+		if err != nil {
+			return setFailed(pairs[idx], err.Error())
+		}
+		changes = append(changes, change{argument: pairs[idx], param: param, val: val, previous: param.Value()})
+	}
+
+	// the new values are in place before any of them is applied, an apply function may read the others
+	mutex.Lock()
+	for _, change := range changes {
+		change.param.value = change.val
+	}
+	mutex.Unlock()
+
+	for idx, change := range changes {
+		if change.param.Apply == nil {
+			continue
+		}
+		if err := change.param.Apply(change.val); err != nil {
+			mutex.Lock()
+			for _, reverted := range changes {
+				reverted.param.value = reverted.previous
+			}
+			mutex.Unlock()
+			for _, applied := range changes[:idx] {
+				if applied.param.Apply != nil {
+					applied.param.Apply(applied.previous)
+				}
+			}
+			return setFailed(change.argument, err.Error())
+		}
+	}
+	return nil
+}
+
+// setFailed returns the error replied when CONFIG SET can not set the parameter named argument.
+func setFailed(argument, reason string) error {
+	return fmt.Errorf("ERR CONFIG SET failed (possibly related to argument '%s') - %s", argument, reason)
+}
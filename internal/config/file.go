@@ -0,0 +1,124 @@
+package config
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"memodb/internal/resp"
+)
+
+// maxIncludeDepth bounds how deeply config files can include each other, which stops include loops.
+const maxIncludeDepth = 16
+
+/*
+	LoadFile loads the redis.conf style config file at path and records it as the file rewritten by CONFIG REWRITE.
+	Every line holds a directive, the name of a parameter followed by its value, or is a comment starting with '#'.
+	Arguments are separated by spaces and can be quoted, "double quoted" arguments understand escapes such as \n or
+	\x41. A value made of several arguments, like the save points of save, is the arguments joined by single spaces.
+	The include directive loads another config file in place, relative paths being relative to the working directory.
+	When a parameter is set several times the last value wins, except for parameters flagged FlagAppend which collect
+	every value.
+
+	Function Signature:
+		func LoadFile(path string) error
+
+	Parameters:
+		- path: Path of the config file. (string)
+
+	Returns:
+		- error - Error telling the file, line and directive at fault, if any, else nil.
+
+	Example Usage:
+		// memodb.conf holds "port 7000\nsave 900 1\nsave 300 10\ninclude /etc/memodb/common.conf\n"
+		err := LoadFile("memodb.conf")
+		// Output err = nil, Get("port") = "7000", Get("save") = "900 1 300 10"
+*/
+func LoadFile(path string) error {
+	absolutePath, err := filepath.Abs(path)
+	if err != nil {
+		return fmt.Errorf("error in reading config file %s: %s", path, err.Error())
+	}
+	if err := loadFile(absolutePath, map[string]bool{}, 0); err != nil {
+		return err
+	}
+	SetFile(absolutePath)
+	return nil
+}
+
+// loadFile loads the directives of a config file, seen holds the parameters already set by the files loaded so far.
+func loadFile(path string, seen map[string]bool, depth int) error {
+	if depth > maxIncludeDepth {
+		return fmt.Errorf("error in reading config file %s: too many nested includes", path)
+	}
+	configFile, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("error in reading config file %s: %s", path, err.Error())
+	}
+	defer configFile.Close()
+
+	scanner := bufio.NewScanner(configFile)
+	scanner.Buffer(make([]byte, 0, 64 * 1024), 1024 * 1024)
+	lineNumber := 0
+	for scanner.Scan() {
+		lineNumber++
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		// lines are split like inline commands, whose only error is unbalanced quotes
+		args, err := resp.SplitInlineArgs(line)
+		if err != nil {
+			err = fmt.Errorf("unbalanced quotes")
+		}
+		if err == nil && len(args) == 2 && strings.ToLower(args[0]) == "include" {
+			// errors of the included file already tell where they are
+			if err := loadFile(args[1], seen, depth + 1); err != nil {
+				return err
+			}
+			continue
+		}
+		if err == nil {
+			err = loadDirective(args, seen, depth > 0)
+		}
+		if err != nil {
+			return fmt.Errorf("error in config file %s at line %d, '%s': %s", path, lineNumber, line, err.Error())
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("error in reading config file %s: %s", path, err.Error())
+	}
+	return nil
+}
+
+// loadDirective sets the parameter of a config file line out of its arguments, included tells whether the line belongs
+// to an included file.
+func loadDirective(args []string, seen map[string]bool, included bool) error {
+	name := strings.ToLower(args[0])
+	if len(args) < 2 || name == "include" {
+		return fmt.Errorf("wrong number of arguments")
+	}
+
+	param := Lookup(name)
+	if param == nil {
+		return fmt.Errorf("bad directive '%s'", args[0])
+	}
+	val := strings.Join(args[1:], " ")
+	if included {
+		markIncluded(param, val)
+	}
+	if param.Flags & FlagAppend != 0 && seen[param.Name] && val != "" {
+		if previous := param.Value(); previous != "" {
+			val = previous + " " + val
+		}
+	}
+	if err := Load(param.Name, val); err != nil {
+		return err
+	}
+	seen[param.Name] = true
+	markLoadedFromFile(param.Name)
+	return nil
+}
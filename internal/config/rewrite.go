@@ -0,0 +1,206 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+)
+
+// rewriteMarker precedes the parameters CONFIG REWRITE adds to the config file.
+const rewriteMarker = "# Generated by CONFIG REWRITE"
+
+var (
+	// fileMutex guards file and makes sure the config file is rewritten by one client at a time
+	fileMutex sync.Mutex
+	// file is the path of the config file the server was started with, empty when there is none
+	file string
+)
+
+// SetFile records the path of the config file the server was started with, it is the file rewritten by Rewrite.
+func SetFile(path string) {
+	fileMutex.Lock()
+	defer fileMutex.Unlock()
+	file = path
+}
+
+// File returns the path of the config file the server was started with, or an empty string if there is none.
+func File() string {
+	fileMutex.Lock()
+	defer fileMutex.Unlock()
+	return file
+}
+
+/*
+	Rewrite updates the config file with the current value of every parameter, as done by CONFIG REWRITE. Lines setting
+	a parameter are replaced in place, comments, blank lines and include directives are kept as they are, and parameters
+	missing from the file are added at its end when they no longer have their default value or were set by an included
+	file, whose value would otherwise win on restart. Included files are never modified and the values that only come
+	from them are kept out of the file, since they are loaded again on restart. The new file replaces the old one
+	atomically, so a crash leaves one or the other.
+
+	Function Signature:
+		func Rewrite() error
+
+	Returns:
+		- error - Error to be replied to the client, if any, else nil.
+
+	Example Usage:
+		// redis.conf holds "# persistence\nappendonly no\n" and appendonly was set to yes with CONFIG SET
+		err := Rewrite()
+		// Output err = nil, redis.conf holds "# persistence\nappendonly yes\n"
+*/
+func Rewrite() error {
+	fileMutex.Lock()
+	defer fileMutex.Unlock()
+	if file == "" {
+		return fmt.Errorf("ERR The server is running without a config file")
+	}
+
+	content, err := os.ReadFile(file)
+	if err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("ERR Rewriting config file: %s", err.Error())
+	}
+	lines := []string{}
+	if len(content) > 0 {
+		lines = strings.Split(strings.TrimSuffix(string(content), "\n"), "\n")
+	}
+
+	rewritten := make([]string, 0, len(lines))
+	written := map[string]bool{}
+	hasMarker := false
+	for _, line := range lines {
+		hasMarker = hasMarker || strings.TrimSpace(line) == rewriteMarker
+		fields := strings.Fields(line)
+		if len(fields) == 0 || strings.HasPrefix(fields[0], "#") {
+			rewritten = append(rewritten, line)
+			continue
+		}
+		param := Lookup(fields[0])
+		if param == nil {
+			rewritten = append(rewritten, line)
+			continue
+		}
+		if written[param.Name] {
+			// a parameter set on several lines is written once, on the first of them
+			continue
+		}
+		written[param.Name] = true
+		if val, isPresent := fileValue(param); isPresent {
+			rewritten = append(rewritten, formatLine(param, val))
+		}
+	}
+
+	for _, param := range Params() {
+		if written[param.Name] || (param.Value() == param.Default && !isLoadedFromFile(param.Name)) {
+			continue
+		}
+		val, isPresent := fileValue(param)
+		if !isPresent || (param.Flags & FlagAppend == 0 && val == includedValue(param.Name)) {
+			// the included files set the parameter to its current value on their own
+			continue
+		}
+		if !hasMarker {
+			rewritten = append(rewritten, rewriteMarker)
+			hasMarker = true
+		}
+		rewritten = append(rewritten, formatLine(param, val))
+	}
+
+	if err := writeFileAtomically(file, strings.Join(rewritten, "\n") + "\n"); err != nil {
+		return fmt.Errorf("ERR Rewriting config file: %s", err.Error())
+	}
+	return nil
+}
+
+// writeFileAtomically writes content to a temporary file that replaces the file at path once it is synced to disk.
+func writeFileAtomically(path, content string) error {
+	tempPath := fmt.Sprintf("%s.tmp-%d", path, os.Getpid())
+	tempFile, err := os.OpenFile(tempPath, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0644)
+	if err != nil {
+		return err
+	}
+	_, err = tempFile.WriteString(content)
+	if err == nil {
+		err = tempFile.Sync()
+	}
+	if closeErr := tempFile.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(tempPath, path)
+	}
+	if err != nil {
+		os.Remove(tempPath)
+	}
+	return err
+}
+
+// fileValue returns the value to write in the config file for a parameter. For parameters flagged FlagAppend, like the
+// save points of save, the values added by the included files are left out since loading adds them again. It returns
+// false when nothing is left to write.
+func fileValue(param *Param) (string, bool) {
+	val := param.Value()
+	included := strings.Fields(includedValue(param.Name))
+	if param.Flags & FlagAppend == 0 || len(included) == 0 {
+		return val, true
+	}
+	words := strings.Fields(val)
+	for start := 0; start + len(included) <= len(words); start++ {
+		if strings.Join(words[start:start + len(included)], " ") == strings.Join(included, " ") {
+			remaining := append(append([]string{}, words[:start]...), words[start + len(included):]...)
+			return strings.Join(remaining, " "), len(remaining) > 0
+		}
+	}
+	// the values of the included files were replaced, they are still added back on restart
+	return val, true
+}
+
+// formatLine formats the line setting a parameter to val in the config file. The words of parameters flagged
+// FlagMultiArg, like the save points of save, are written as several arguments since loading joins them back.
+func formatLine(param *Param, val string) string {
+	words := strings.Fields(val)
+	if param.Flags & FlagMultiArg == 0 || len(words) < 2 || strings.Join(words, " ") != val {
+		return param.Name + " " + formatValue(val)
+	}
+	for idx, word := range words {
+		words[idx] = formatValue(word)
+	}
+	return param.Name + " " + strings.Join(words, " ")
+}
+
+// formatValue quotes a value when it is empty or holds characters that would split it into several arguments, quotes
+// and control characters are escaped.
+func formatValue(val string) string {
+	if val != "" && !strings.ContainsAny(val, " \t\r\n\"'\\") {
+		return val
+	}
+
+	var builder strings.Builder
+	builder.WriteByte('"')
+	for idx := 0; idx < len(val); idx++ {
+		switch char := val[idx]; {
+			case char == '\\' || char == '"': {
+				builder.WriteByte('\\')
+				builder.WriteByte(char)
+			}
+			case char == '\n': {
+				builder.WriteString("\\n")
+			}
+			case char == '\r': {
+				builder.WriteString("\\r")
+			}
+			case char == '\t': {
+				builder.WriteString("\\t")
+			}
+			case char < 0x20 || char == 0x7f: {
+				builder.WriteString(fmt.Sprintf("\\x%02x", char))
+			}
+			default: {
+				builder.WriteByte(char)
+			}
+		}
+	}
+	builder.WriteByte('"')
+	return builder.String()
+}
@@ -0,0 +1,149 @@
+package glob
+
+/*
+	Match checks if str matches a glob-style pattern, the way patterns are understood by KEYS and CONFIG GET:
+		- * matches any sequence of characters, including an empty one
+		- ? matches any single character
+		- [abc] matches one of the characters between the brackets, [^abc] any character but them and [a-z] any
+		  character of the range
+		- \x matches the character x, even when it is one of the special characters above
+	Patterns are matched byte by byte, with letters compared case insensitively when nocase is set. A '*' is only
+	backtracked to once per mismatch, so matching never takes more than len(pattern) * len(str) steps.
+
+	Function Signature:
+		func Match(pattern, str string, nocase bool) bool
+
+	Parameters:
+		- pattern: The glob-style pattern. (string)
+		- str: The string matched against the pattern. (string)
+		- nocase: Whether letters are compared case insensitively. (bool)
+
+	Returns:
+		- bool - Whether the whole string matches the pattern.
+
+	Example Usage:
+		matched := Match("h[ae]llo*", "hello world", false)
+		// Output matched = true
+		matched := Match("APPEND*", "appendfsync", true)
+		// Output matched = true
+*/
+func Match(pattern, str string, nocase bool) bool {
+	p, s := 0, 0
+	// position in the pattern right after the last '*' and position in str it is currently matched up to
+	starP, starS := -1, -1
+	for s < len(str) {
+		if p < len(pattern) {
+			switch pattern[p] {
+				case '*': {
+					for p < len(pattern) && pattern[p] == '*' {
+						p++
+					}
+					if p == len(pattern) {
+						return true
+					}
+					starP, starS = p, s
+					continue
+				}
+				case '?': {
+					p++
+					s++
+					continue
+				}
+				case '[': {
+					end, matched := matchClass(pattern, p + 1, str[s], nocase)
+					if matched {
+						p = end
+						s++
+						continue
+					}
+				}
+				default: {
+					literal, width := pattern[p], 1
+					if literal == '\\' && p + 1 < len(pattern) {
+						literal, width = pattern[p + 1], 2
+					}
+					if equalFold(literal, str[s], nocase) {
+						p += width
+						s++
+						continue
+					}
+				}
+			}
+		}
+
+		// mismatch, the last '*' swallows one more character
+		if starP < 0 {
+			return false
+		}
+		starS++
+		p, s = starP, starS
+	}
+
+	for p < len(pattern) && pattern[p] == '*' {
+		p++
+	}
+	return p == len(pattern)
+}
+
+// matchClass matches char against the character class starting at pattern[start], right after its '['. It returns
+// the position following the closing ']', or the end of the pattern when the class is not closed.
+func matchClass(pattern string, start int, char byte, nocase bool) (int, bool) {
+	p := start
+	negated := p < len(pattern) && pattern[p] == '^'
+	if negated {
+		p++
+	}
+
+	matched := false
+	for p < len(pattern) && pattern[p] != ']' {
+		switch {
+			case pattern[p] == '\\' && p + 1 < len(pattern): {
+				p++
+				if equalFold(pattern[p], char, nocase) {
+					matched = true
+				}
+			}
+			case p + 2 < len(pattern) && pattern[p + 1] == '-': {
+				low, high := pattern[p], pattern[p + 2]
+				if low > high {
+					low, high = high, low
+				}
+				if nocase {
+					low, high = toLower(low), toLower(high)
+					if toLower(char) >= low && toLower(char) <= high {
+						matched = true
+					}
+				} else if char >= low && char <= high {
+					matched = true
+				}
+				p += 2
+			}
+			default: {
+				if equalFold(pattern[p], char, nocase) {
+					matched = true
+				}
+			}
+		}
+		p++
+	}
+	if p < len(pattern) {
+		// skipping the closing ']'
+		p++
+	}
+
+	return p, matched != negated
+}
+
+func equalFold(a, b byte, nocase bool) bool {
+	if nocase {
+		return toLower(a) == toLower(b)
+	}
+	return a == b
+}
+
+func toLower(char byte) byte {
+	if char >= 'A' && char <= 'Z' {
+		return char + ('a' - 'A')
+	}
+	return char
+}
@@ -0,0 +1,207 @@
+package resp
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strconv"
+	"unicode"
+)
+
+// ReadCommand reads the next command sent by a client. Commands are normally sent as RESP arrays of bulk strings,
+// but like Redis, anything that does not start with '*' is read as an inline command: a single line of space
+// separated arguments that may be quoted, as typed in telnet or netcat sessions.
+// The command is always returned as an array of bulk strings, empty inline lines are skipped.
+func (r *Reader) ReadCommand() (*RespType, error) {
+	for {
+		firstByte, err := r.rd.Peek(1)
+		if err != nil {
+			return nil, err
+		}
+		if DataType(firstByte) == Array {
+			return r.readMultibulk()
+		}
+
+		line, err := r.readInlineLine()
+		if err != nil {
+			return nil, err
+		}
+		arguments, err := SplitInlineArgs(line)
+		if err != nil {
+			return nil, err
+		}
+		if len(arguments) == 0 {
+			continue
+		}
+		command := NewBulkStringArray(arguments...)
+		return &command, nil
+	}
+}
+
+// readMultibulk reads a command sent as a RESP array. Like in Redis its elements must be bulk strings, so a command can
+// not nest aggregates.
+func (r *Reader) readMultibulk() (*RespType, error) {
+	line, err := r.readLine()
+	if err != nil {
+		return nil, err
+	}
+	size, err := parseLength(line, maxArrayLength)
+	if err != nil {
+		return nil, err
+	}
+	command := &RespType{DataType: Array}
+	if size < 0 {
+		command.IsNull = true
+		return command, nil
+	}
+
+	command.Array = make([]*RespType, 0, preallocLength(size))
+	for i := 0; i < size; i++ {
+		firstByte, err := r.rd.Peek(1)
+		if err != nil {
+			return nil, unexpectedEOF(err)
+		}
+		if DataType(firstByte) != BulkString {
+			return nil, fmt.Errorf("deserialization error: expected '$', got '%s'", firstByte)
+		}
+		elem, err := r.readResp(1)
+		if err != nil {
+			return nil, unexpectedEOF(err)
+		}
+		command.Array = append(command.Array, elem)
+	}
+	return command, nil
+}
+
+// readInlineLine reads a line terminated by LF, with an optional CR before it, and returns it without the terminator.
+func (r *Reader) readInlineLine() (string, error) {
+	var line []byte
+	for {
+		chunk, err := r.rd.ReadSlice('\n')
+		if err == nil || err == bufio.ErrBufferFull {
+			line = append(line, chunk...)
+		}
+		if len(line) > maxLineLength {
+			return "", fmt.Errorf("deserialization error: too big inline request")
+		}
+		if err == nil {
+			break
+		}
+		if err != bufio.ErrBufferFull {
+			if err == io.EOF && (len(line) > 0 || len(chunk) > 0) {
+				return "", unexpectedEOF(err)
+			}
+			return "", err
+		}
+	}
+
+	line = line[:len(line)-1]
+	if len(line) > 0 && line[len(line)-1] == '\r' {
+		line = line[:len(line)-1]
+	}
+	return string(line), nil
+}
+
+// SplitInlineArgs splits an inline command into its arguments following the rules of Redis' sdssplitargs.
+// Arguments are separated by whitespace and may be quoted. Double quoted arguments support the escapes
+// \n \r \t \b \a \\ \" and \xHH, single quoted arguments only support \'. A closing quote must be followed by
+// whitespace or the end of the line.
+func SplitInlineArgs(line string) ([]string, error) {
+	arguments := []string{}
+	idx := 0
+	for {
+		for idx < len(line) && unicode.IsSpace(rune(line[idx])) {
+			idx++
+		}
+		if idx >= len(line) {
+			return arguments, nil
+		}
+
+		var current []byte
+		inDoubleQuotes, inSingleQuotes, done := false, false, false
+		for !done {
+			if inDoubleQuotes {
+				if idx >= len(line) {
+					return nil, fmt.Errorf("deserialization error: unbalanced quotes in request")
+				}
+				if line[idx] == '\\' && idx + 3 < len(line) && line[idx + 1] == 'x' && isHexDigit(line[idx + 2]) && isHexDigit(line[idx + 3]) {
+					hexByte, _ := strconv.ParseUint(line[idx + 2:idx + 4], 16, 8)
+					current = append(current, byte(hexByte))
+					idx += 3
+				} else if line[idx] == '\\' && idx + 1 < len(line) {
+					idx++
+					switch line[idx] {
+						case 'n': {
+							current = append(current, '\n')
+						}
+						case 'r': {
+							current = append(current, '\r')
+						}
+						case 't': {
+							current = append(current, '\t')
+						}
+						case 'b': {
+							current = append(current, '\b')
+						}
+						case 'a': {
+							current = append(current, '\a')
+						}
+						default: {
+							current = append(current, line[idx])
+						}
+					}
+				} else if line[idx] == '"' {
+					// closing quote must be followed by a space or nothing at all
+					if idx + 1 < len(line) && !unicode.IsSpace(rune(line[idx + 1])) {
+						return nil, fmt.Errorf("deserialization error: unbalanced quotes in request")
+					}
+					done = true
+				} else {
+					current = append(current, line[idx])
+				}
+			} else if inSingleQuotes {
+				if idx >= len(line) {
+					return nil, fmt.Errorf("deserialization error: unbalanced quotes in request")
+				}
+				if line[idx] == '\\' && idx + 1 < len(line) && line[idx + 1] == '\'' {
+					idx++
+					current = append(current, '\'')
+				} else if line[idx] == '\'' {
+					// closing quote must be followed by a space or nothing at all
+					if idx + 1 < len(line) && !unicode.IsSpace(rune(line[idx + 1])) {
+						return nil, fmt.Errorf("deserialization error: unbalanced quotes in request")
+					}
+					done = true
+				} else {
+					current = append(current, line[idx])
+				}
+			} else {
+				if idx >= len(line) {
+					break
+				}
+				switch line[idx] {
+					case ' ', '\n', '\r', '\t', '\000': {
+						done = true
+					}
+					case '"': {
+						inDoubleQuotes = true
+					}
+					case '\'': {
+						inSingleQuotes = true
+					}
+					default: {
+						current = append(current, line[idx])
+					}
+				}
+			}
+			if idx < len(line) {
+				idx++
+			}
+		}
+		arguments = append(arguments, string(current))
+	}
+}
+
+func isHexDigit(char byte) bool {
+	return (char >= '0' && char <= '9') || (char >= 'a' && char <= 'f') || (char >= 'A' && char <= 'F')
+}
@@ -0,0 +1,247 @@
+package resp
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"strconv"
+)
+
+const (
+	// maxLineLength is the longest header line (type byte, length, simple string) accepted from a peer.
+	maxLineLength = 64 * 1024
+	// maxBulkLength mirrors Redis' default proto-max-bulk-len of 512MB.
+	maxBulkLength = 512 * 1024 * 1024
+	// maxArrayLength caps the number of elements a single aggregate may declare.
+	maxArrayLength = 1024 * 1024
+	// maxNestingDepth caps how deeply aggregates may be nested, each level is read by a recursive call.
+	maxNestingDepth = 32
+	// maxPreallocLength is the largest payload or aggregate allocated at once from its declared length, larger ones
+	// grow as their contents arrive so a peer can not make us allocate memory it does not send.
+	maxPreallocLength = 64 * 1024
+)
+
+// Reader reads Redis Serialization Protocol (RESP) messages from a stream.
+// It buffers the underlying reader, so a single message may span several network reads and several
+// pipelined messages may arrive in a single read. Every call to ReadResp returns exactly one complete message.
+type Reader struct {
+	rd *bufio.Reader
+}
+
+// NewReader returns a Reader that reads RESP messages from rd.
+func NewReader(rd io.Reader) *Reader {
+	return &Reader{
+		rd: bufio.NewReaderSize(rd, 16*1024),
+	}
+}
+
+// Buffered returns the number of bytes that have been read from the stream but not consumed yet.
+func (r *Reader) Buffered() int {
+	return r.rd.Buffered()
+}
+
+// ReadResp reads the next complete RESP message from the stream.
+// io.EOF is returned only when the stream ends cleanly between two messages, a stream that ends in the
+// middle of a message returns io.ErrUnexpectedEOF.
+func (r *Reader) ReadResp() (*RespType, error) {
+	return r.readResp(0)
+}
+
+// readResp reads the next complete RESP message, nested in depth aggregates.
+func (r *Reader) readResp(depth int) (*RespType, error) {
+	line, err := r.readLine()
+	if err != nil {
+		return nil, err
+	}
+	if len(line) == 0 {
+		return nil, fmt.Errorf("deserialization error: missing RESP Data Type")
+	}
+
+	dataType := DataType(line[:1])
+	if !IsValidRespDataType(dataType) {
+		return nil, fmt.Errorf("deserialization error: malformed RESP data type")
+	}
+
+	deserializedMessage := &RespType{DataType: dataType}
+	switch dataType {
+		case String, Error, BigNumber: {
+			deserializedMessage.String = string(line[1:])
+		}
+		case Integer: {
+			num, err := strconv.ParseInt(string(line[1:]), 10, 64)
+			if err != nil {
+				return nil, fmt.Errorf("deserialization error: found RESP data type %s but not an int value", dataType)
+			}
+			deserializedMessage.Number = int(num)
+		}
+		case Null: {
+			deserializedMessage.IsNull = true
+		}
+		case Boolean: {
+			switch string(line[1:]) {
+				case "t": {
+					deserializedMessage.Boolean = true
+				}
+				case "f": {
+					deserializedMessage.Boolean = false
+				}
+				default: {
+					return nil, fmt.Errorf("deserialization error: found RESP data type %s but not a boolean value", dataType)
+				}
+			}
+		}
+		case Double: {
+			num, err := strconv.ParseFloat(string(line[1:]), 64)
+			if err != nil {
+				return nil, fmt.Errorf("deserialization error: found RESP data type %s but not a double value", dataType)
+			}
+			deserializedMessage.Double = num
+		}
+		case BulkString, BulkError, Verbatim: {
+			size, err := parseLength(line, maxBulkLength)
+			if err != nil {
+				return nil, err
+			}
+			if size < 0 {
+				deserializedMessage.IsNull = true
+				return deserializedMessage, nil
+			}
+
+			var payload bytes.Buffer
+			if size + 2 <= maxPreallocLength {
+				payload.Grow(size + 2)
+			}
+			if _, err := io.CopyN(&payload, r.rd, int64(size + 2)); err != nil {
+				return nil, unexpectedEOF(err)
+			}
+			data := payload.Bytes()
+			if data[size] != '\r' || data[size+1] != '\n' {
+				return nil, fmt.Errorf("deserialization error: bulk string of length %d is not terminated by CRLF", size)
+			}
+			deserializedMessage.String = string(data[:size])
+		}
+		case Array, Set, Push, Map, Attribute: {
+			size, err := parseLength(line, maxArrayLength)
+			if err != nil {
+				return nil, err
+			}
+			if size < 0 {
+				deserializedMessage.IsNull = true
+				return deserializedMessage, nil
+			}
+			if dataType == Map || dataType == Attribute {
+				// maps declare the number of key-value pairs
+				size *= 2
+			}
+			if depth >= maxNestingDepth {
+				return nil, fmt.Errorf("deserialization error: aggregates nested more than %d levels deep", maxNestingDepth)
+			}
+
+			respArr := make([]*RespType, 0, preallocLength(size))
+			for i := 0; i < size; i++ {
+				elem, err := r.readResp(depth + 1)
+				if err != nil {
+					return nil, unexpectedEOF(err)
+				}
+				respArr = append(respArr, elem)
+			}
+			deserializedMessage.Array = respArr
+
+			if dataType == Attribute {
+				// attributes carry auxiliary data about the reply that follows them, which is what the caller is after
+				return r.readResp(depth)
+			}
+		}
+	}
+
+	return deserializedMessage, nil
+}
+
+// BulkPayload reads the header of a bulk payload that is not terminated by CRLF, which is how a master transfers
+// its RDB snapshot to a replica after a FULLRESYNC. The returned reader yields the payload as it arrives, so it is never
+// held in memory as a whole, and it must be read to its end before the next message is read.
+func (r *Reader) BulkPayload() (io.Reader, error) {
+	// a master preparing the snapshot keeps the connection alive by sending newlines
+	for {
+		b, err := r.rd.Peek(1)
+		if err != nil {
+			return nil, err
+		}
+		if b[0] != '\n' {
+			break
+		}
+		r.rd.ReadByte()
+	}
+
+	line, err := r.readLine()
+	if err != nil {
+		return nil, err
+	}
+	if len(line) == 0 || DataType(line[:1]) != BulkString {
+		return nil, fmt.Errorf("deserialization error: expected a bulk payload")
+	}
+	size, err := strconv.ParseInt(string(line[1:]), 10, 64)
+	if err != nil || size < 0 {
+		return nil, fmt.Errorf("deserialization error: found RESP data type %s but not a valid size", BulkString)
+	}
+
+	return io.LimitReader(r.rd, size), nil
+}
+
+// readLine reads a single CRLF terminated line and returns it without the terminator.
+func (r *Reader) readLine() ([]byte, error) {
+	var line []byte
+	for {
+		chunk, err := r.rd.ReadSlice('\n')
+		if err == nil || err == bufio.ErrBufferFull {
+			line = append(line, chunk...)
+		}
+		if len(line) > maxLineLength {
+			return nil, fmt.Errorf("deserialization error: line exceeds %d bytes", maxLineLength)
+		}
+		if err == bufio.ErrBufferFull {
+			continue
+		}
+		if err != nil {
+			if err == io.EOF && (len(line) > 0 || len(chunk) > 0) {
+				return nil, io.ErrUnexpectedEOF
+			}
+			return nil, err
+		}
+		break
+	}
+
+	if len(line) < 2 || line[len(line)-2] != '\r' {
+		return nil, fmt.Errorf("deserialization error: line is not terminated by CRLF")
+	}
+	return line[:len(line)-2], nil
+}
+
+// parseLength parses the length header of a bulk string or an aggregate, -1 denotes a null value.
+func parseLength(line []byte, limit int) (int, error) {
+	size, err := strconv.Atoi(string(line[1:]))
+	if err != nil || size < -1 {
+		return 0, fmt.Errorf("deserialization error: found RESP data type %s but not a valid size", DataType(line[:1]))
+	}
+	if size > limit {
+		return 0, fmt.Errorf("deserialization error: size %d exceeds the maximum of %d", size, limit)
+	}
+	return size, nil
+}
+
+// preallocLength returns how many elements to allocate up front for an aggregate declaring size elements.
+func preallocLength(size int) int {
+	if size > maxPreallocLength {
+		return maxPreallocLength
+	}
+	return size
+}
+
+// unexpectedEOF converts a clean EOF found in the middle of a message into io.ErrUnexpectedEOF.
+func unexpectedEOF(err error) error {
+	if err == io.EOF {
+		return io.ErrUnexpectedEOF
+	}
+	return err
+}
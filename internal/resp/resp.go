@@ -7,13 +7,30 @@ const (
 	Integer DataType = ":"
 	BulkString DataType = "$"
 	Array DataType = "*"
+
+	// RESP3 data types, these are only sent to clients that negotiated protocol 3 via HELLO
+	Null DataType = "_"
+	Boolean DataType = "#"
+	Double DataType = ","
+	BigNumber DataType = "("
+	BulkError DataType = "!"
+	Verbatim DataType = "="
+	Map DataType = "%"
+	Set DataType = "~"
+	Push DataType = ">"
+	Attribute DataType = "|"
 )
 
+// RespType is the in-memory representation of a RESP value.
+// Aggregates keep their elements in Array, for a Map the keys and values are stored alternately (k1, v1, k2, v2...).
+// Verbatim strings keep their 3 character format followed by a colon (e.g. "txt:") at the start of String.
 type RespType struct {
 	DataType DataType;
 	String string;
 	Number int;
+	Double float64;
 	Boolean bool;
+	IsNull bool;
 	Array []*RespType;
 }
 
@@ -22,8 +39,11 @@ func IsValidRespDataType(dataType DataType) bool {
 		case String, Error, Integer, BulkString, Array: {
 			return true;
 		}
+		case Null, Boolean, Double, BigNumber, BulkError, Verbatim, Map, Set, Push, Attribute: {
+			return true;
+		}
 		default: {
 			return false;
 		}
 	}
-}
\ No newline at end of file
+}
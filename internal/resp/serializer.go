@@ -2,35 +2,272 @@ package resp
 
 import (
 	"fmt"
+	"math"
+	"strconv"
+	"strings"
 )
 
 // SerializeResp converts a RespType object into a valid Redis Serialization Protocol (RESP) string.
+// Every value is serialized as its own data type, use SerializeRespProtocol when replying to a client.
 func SerializeResp(resp RespType) (string, error) {
-	dataType := resp.DataType;
+	var builder strings.Builder
+	if err := serializeResp(&builder, &resp, 0); err != nil {
+		return "", err
+	}
+	return builder.String(), nil
+}
+
+// SerializeRespProtocol converts a RespType object into a RESP string understood by a client speaking the given
+// protocol version. RESP3 only types are downgraded to their RESP2 counterparts for protocol 2 (maps and sets become
+// flat arrays, doubles and big numbers become bulk strings, booleans become integers), while protocol 3 sends every
+// null as the RESP3 null.
+func SerializeRespProtocol(resp RespType, protocol int) (string, error) {
+	var builder strings.Builder
+	if err := serializeResp(&builder, &resp, protocol); err != nil {
+		return "", err
+	}
+	return builder.String(), nil
+}
+
+// serializeResp appends the RESP representation of resp to builder, aggregates are serialized recursively.
+// A protocol of 0 keeps every value as its own data type.
+func serializeResp(builder *strings.Builder, resp *RespType, protocol int) error {
+	if resp == nil {
+		return fmt.Errorf("error occurred during serialization: nil value")
+	}
+
+	dataType := resp.DataType
+	if resp.IsNull && protocol == 3 {
+		dataType = Null
+	}
+	if protocol == 2 {
+		dataType = downgradeDataType(resp)
+	}
 
 	switch dataType {
-		case String: {
-			return "+" + resp.String + "\r\n", nil
+		case String, Error: {
+			str := resp.String
+			if resp.DataType == BulkError {
+				// bulk errors may span several lines, simple errors can not
+				str = strings.NewReplacer("\r", " ", "\n", " ").Replace(str)
+			}
+			if strings.ContainsAny(str, "\r\n") {
+				return fmt.Errorf("error occurred during serialization: simple strings can not contain CR or LF")
+			}
+			builder.WriteString(string(dataType) + str + "\r\n")
+		}
+		case Integer: {
+			num := resp.Number
+			if resp.DataType == Boolean {
+				num = 0
+				if resp.Boolean {
+					num = 1
+				}
+			}
+			builder.WriteString(":" + fmt.Sprint(num) + "\r\n")
 		}
 		case BulkString: {
-			return "$" + fmt.Sprint(len(resp.String)) + "\r\n" + resp.String + "\r\n", nil
+			if resp.IsNull {
+				builder.WriteString("$-1\r\n")
+				return nil
+			}
+			str := resp.String
+			switch resp.DataType {
+				case Double: {
+					str = formatDouble(resp.Double)
+				}
+				case Verbatim: {
+					str = verbatimContent(resp.String)
+				}
+			}
+			builder.WriteString("$" + fmt.Sprint(len(str)) + "\r\n" + str + "\r\n")
+		}
+		case BulkError, Verbatim: {
+			builder.WriteString(string(dataType) + fmt.Sprint(len(resp.String)) + "\r\n" + resp.String + "\r\n")
+		}
+		case Null: {
+			builder.WriteString("_\r\n")
+		}
+		case Boolean: {
+			if resp.Boolean {
+				builder.WriteString("#t\r\n")
+			} else {
+				builder.WriteString("#f\r\n")
+			}
+		}
+		case Double: {
+			builder.WriteString("," + formatDouble(resp.Double) + "\r\n")
 		}
-		case Array: {
+		case BigNumber: {
+			builder.WriteString("(" + resp.String + "\r\n")
+		}
+		case Array, Set, Push, Map, Attribute: {
+			if resp.IsNull {
+				builder.WriteString("*-1\r\n")
+				return nil
+			}
 			size := len(resp.Array)
-			response := "*" + fmt.Sprint(size) + "\r\n"
-
+			if dataType == Map || dataType == Attribute {
+				if size%2 != 0 {
+					return fmt.Errorf("error occurred during serialization: map has a key without a value")
+				}
+				// maps declare the number of key-value pairs
+				size /= 2
+			}
+			builder.WriteString(string(dataType) + fmt.Sprint(size) + "\r\n")
 			for _, val := range resp.Array {
-				currResponse, err := SerializeResp(*val)
-				if err != nil {
-					return "", err
+				if err := serializeResp(builder, val, protocol); err != nil {
+					return err
 				}
-				response += currResponse
 			}
+		}
+		default: {
+			return fmt.Errorf("error occurred during serialization: data type not found")
+		}
+	}
+	return nil
+}
 
-			return response, nil
+// downgradeDataType returns the RESP2 data type a value is sent as to a client speaking protocol 2.
+func downgradeDataType(resp *RespType) DataType {
+	switch resp.DataType {
+		case Null: {
+			return BulkString
+		}
+		case Boolean: {
+			return Integer
+		}
+		case Double, BigNumber, Verbatim: {
+			return BulkString
+		}
+		case BulkError: {
+			return Error
+		}
+		case Map, Set, Push, Attribute: {
+			return Array
+		}
+		default: {
+			return resp.DataType
+		}
+	}
+}
+
+// formatDouble formats a double the way Redis does, with inf, -inf and nan for the special values.
+func formatDouble(num float64) string {
+	switch {
+		case math.IsInf(num, 1): {
+			return "inf"
+		}
+		case math.IsInf(num, -1): {
+			return "-inf"
+		}
+		case math.IsNaN(num): {
+			return "nan"
 		}
 		default: {
-			return "", fmt.Errorf("error occurred during serialization: data type not found")
+			return strconv.FormatFloat(num, 'g', -1, 64)
 		}
 	}
-}
\ No newline at end of file
+}
+
+// verbatimContent strips the format prefix (e.g. "txt:") off a verbatim string.
+func verbatimContent(str string) string {
+	if len(str) >= 4 && str[3] == ':' {
+		return str[4:]
+	}
+	return str
+}
+
+// NewSimpleString returns a RESP simple string, e.g. +OK
+func NewSimpleString(str string) RespType {
+	return RespType{DataType: String, String: str}
+}
+
+// NewError returns a RESP error, msg should start with an error code such as ERR or WRONGTYPE.
+func NewError(msg string) RespType {
+	return RespType{DataType: Error, String: msg}
+}
+
+// NewInteger returns a RESP integer.
+func NewInteger(num int) RespType {
+	return RespType{DataType: Integer, Number: num}
+}
+
+// NewBulkString returns a binary safe RESP bulk string.
+func NewBulkString(str string) RespType {
+	return RespType{DataType: BulkString, String: str}
+}
+
+// NewNullBulkString returns the null bulk string, which is how a missing value is represented.
+func NewNullBulkString() RespType {
+	return RespType{DataType: BulkString, IsNull: true}
+}
+
+// NewArray returns a RESP array holding the given elements, which may be arrays themselves.
+func NewArray(elems ...RespType) RespType {
+	respArr := make([]*RespType, 0, len(elems))
+	for i := range elems {
+		respArr = append(respArr, &elems[i])
+	}
+	return RespType{DataType: Array, Array: respArr}
+}
+
+// NewNullArray returns the null array.
+func NewNullArray() RespType {
+	return RespType{DataType: Array, IsNull: true}
+}
+
+// NewBulkStringArray returns a RESP array of bulk strings, which is how commands are sent over the wire.
+func NewBulkStringArray(strs ...string) RespType {
+	respArr := make([]*RespType, 0, len(strs))
+	for _, str := range strs {
+		respArr = append(respArr, &RespType{DataType: BulkString, String: str})
+	}
+	return RespType{DataType: Array, Array: respArr}
+}
+
+// NewNull returns the RESP3 null, which is sent as a null bulk string to RESP2 clients.
+func NewNull() RespType {
+	return RespType{DataType: Null, IsNull: true}
+}
+
+// NewBoolean returns a RESP3 boolean, which is sent as the integer 1 or 0 to RESP2 clients.
+func NewBoolean(boolean bool) RespType {
+	return RespType{DataType: Boolean, Boolean: boolean}
+}
+
+// NewDouble returns a RESP3 double, which is sent as a bulk string to RESP2 clients.
+func NewDouble(num float64) RespType {
+	return RespType{DataType: Double, Double: num}
+}
+
+// NewBigNumber returns a RESP3 big number, num must hold the decimal representation of an integer.
+func NewBigNumber(num string) RespType {
+	return RespType{DataType: BigNumber, String: num}
+}
+
+// NewVerbatimString returns a RESP3 verbatim string, format is a 3 character hint such as txt or mkd.
+func NewVerbatimString(format, str string) RespType {
+	return RespType{DataType: Verbatim, String: format + ":" + str}
+}
+
+// NewMap returns a RESP3 map, elems holds the keys and values alternately.
+func NewMap(elems ...RespType) RespType {
+	respMap := NewArray(elems...)
+	respMap.DataType = Map
+	return respMap
+}
+
+// NewSet returns a RESP3 set, which is sent as an array to RESP2 clients.
+func NewSet(elems ...RespType) RespType {
+	respSet := NewArray(elems...)
+	respSet.DataType = Set
+	return respSet
+}
+
+// NewPush returns a RESP3 push message, which is sent as an array to RESP2 clients.
+func NewPush(elems ...RespType) RespType {
+	respPush := NewArray(elems...)
+	respPush.DataType = Push
+	return respPush
+}
@@ -0,0 +1,41 @@
+package rdb
+
+import "hash/crc64"
+
+// Redis checksums RDB files with the CRC-64/Jones variant: reflected polynomial 0x95AC9329AC4BC9B5, an initial
+// value of 0 and no final xor. hash/crc64 only implements variants that invert the crc, so only its table is reused.
+var crc64Table = crc64.MakeTable(0x95AC9329AC4BC9B5)
+
+/*
+	crc64Update returns the result of adding the bytes in data to the Redis crc64 checksum crc.
+
+	Function Signature:
+		func crc64Update(crc uint64, data []byte) uint64
+
+	Parameters:
+		- crc: The checksum of the bytes seen so far, 0 for a new checksum. (uint64)
+		- data: The bytes to add to the checksum. ([]byte)
+
+	Returns:
+		- uint64 - The updated checksum.
+
+	Example Usage:
+		crc := crc64Update(0, []byte("123456789"))
+		// Output crc = 0xe9c6d914c4b8d9ca
+*/
+func crc64Update(crc uint64, data []byte) uint64 {
+	for _, b := range data {
+		crc = crc64Table[byte(crc)^b] ^ (crc >> 8)
+	}
+	return crc
+}
+
+// crc64Writer computes the checksum of everything written to it.
+type crc64Writer struct {
+	crc uint64
+}
+
+func (w *crc64Writer) Write(data []byte) (int, error) {
+	w.crc = crc64Update(w.crc, data)
+	return len(data), nil
+}
@@ -0,0 +1,79 @@
+package rdb
+
+import "fmt"
+
+/*
+	lzfDecompress expands data compressed with LZF, which Redis uses for long strings when rdbcompression is on.
+	The compressed stream is a sequence of chunks, each starting with a control byte: below 32 it is followed by
+	control + 1 literal bytes, otherwise its 3 high bits (plus an extra byte when they are all set) hold the length
+	of a back reference and its 5 low bits, along with the next byte, hold the offset to copy from.
+
+	Function Signature:
+		func lzfDecompress(data []byte, expectedLength int) ([]byte, error)
+
+	Parameters:
+		- data: The compressed bytes. ([]byte)
+		- expectedLength: The length of the uncompressed string, as stored in the dump. (int)
+
+	Returns:
+		- []byte - The uncompressed bytes.
+		- error - Error, if the data is corrupt or does not expand to expectedLength bytes, else nil.
+
+	Example Usage:
+		out, err := lzfDecompress([2 97 98 99 32 2], 6)
+		// Output out = "abcabc", err = nil
+*/
+func lzfDecompress(data []byte, expectedLength int) ([]byte, error) {
+	out := make([]byte, 0, expectedLength)
+	idx := 0
+	for idx < len(data) {
+		ctrl := int(data[idx])
+		idx++
+
+		if ctrl < 32 {
+			// literal run
+			length := ctrl + 1
+			if idx + length > len(data) {
+				return nil, fmt.Errorf("lzf literal run exceeds the compressed data")
+			}
+			if len(out) + length > expectedLength {
+				return nil, fmt.Errorf("lzf data expands beyond %d bytes", expectedLength)
+			}
+			out = append(out, data[idx:idx + length]...)
+			idx += length
+			continue
+		}
+
+		// back reference
+		length := ctrl >> 5
+		if length == 7 {
+			if idx >= len(data) {
+				return nil, fmt.Errorf("lzf back reference is truncated")
+			}
+			length += int(data[idx])
+			idx++
+		}
+		if idx >= len(data) {
+			return nil, fmt.Errorf("lzf back reference is truncated")
+		}
+		ref := len(out) - ((ctrl & 0x1F) << 8) - int(data[idx]) - 1
+		idx++
+		length += 2
+
+		if ref < 0 {
+			return nil, fmt.Errorf("lzf back reference points before the start of the data")
+		}
+		if len(out) + length > expectedLength {
+			return nil, fmt.Errorf("lzf data expands beyond %d bytes", expectedLength)
+		}
+		// the reference may overlap the bytes being produced, so copy one byte at a time
+		for i := 0; i < length; i++ {
+			out = append(out, out[ref + i])
+		}
+	}
+
+	if len(out) != expectedLength {
+		return nil, fmt.Errorf("lzf data expands to %d bytes instead of %d", len(out), expectedLength)
+	}
+	return out, nil
+}
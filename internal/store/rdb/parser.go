@@ -1,416 +1,373 @@
 package rdb
 
 import (
+	"bufio"
 	"bytes"
 	"encoding/binary"
 	"fmt"
+	"io"
 	"os"
+	"strconv"
 	"time"
 )
 
 // common RDB section indicators
 var (
 	RDBHeader = []byte("REDIS")
+	SlotInfoHeader = byte(0xF4)
+	FunctionHeader = byte(0xF5)
+	ModuleAuxHeader = byte(0xF7)
+	IdleHeader = byte(0xF8)
+	FreqHeader = byte(0xF9)
 	MetadataHeader = byte(0xFA)
-	DatabaseHeader = byte(0xFE)
-	KeyExpiryHeaderSec = byte(0xFD)
+	ResizeDBHeader = byte(0xFB)
 	KeyExpiryHeaderMS = byte(0xFC)
+	KeyExpiryHeaderSec = byte(0xFD)
+	DatabaseHeader = byte(0xFE)
 	EOFHeader = byte(0xFF)
 )
 
-/*
-	ParseRdbFile is responsible for reading an RDB file dump, validating it, and finally returning a valid
-	value of the type *RDBType.
-
-	Function Signature:
-		func ParseRdbFile(dirPath, fileName string) (*RDBType, error)
-
-	Parameters:
-		- dirPath: Directory where the RDB dump is present. (string)
-		- fileName: Name of the RDB dump. (string)
-
-	Returns:
-		- *RDBType - A reference to a RDBType which is the parsed out form of the RDB dump.
-		- error - Error, if any, else nil.
-
-	Example Usage:
-	  rdbData, err := ParseRdbFile("xyz/dumps", "dump.rdb")
-	  // Output
-	  rdbData = {
-	  		Version: 7.2.0
-			Databases: [
-				{
-					DatabaseNumber: 0,
-					HashTableSize: 5,
-					ExpiryHashTableSize: 5,
-					KVMap: {
-						"foo": {
-							Value: "bar",
-							ExpireAt: 1729006766003
-						},
-						"appple": {
-							Value: "banana"
-						}
-					}
-				}
-			]
-		},
-		error = nil
-*/
-func ParseRdbFile(dirPath, fileName string) (*RDBType, error) {
-	parsedRdb := new(RDBType)
-	parsedRdb.Databases = []RDBDatabase{}
-
-	data, err := readRdbFile(dirPath, fileName)
-	if err != nil {
-		return nil, err
-	}
-
-	isValidRdb, err := validateRdbFile(data)
-	if err != nil {
-		return nil, err
-	} else if !isValidRdb {
-		return nil, fmt.Errorf("malformed rdb file")
-	}
-
-	parsedRdb.Version = string(data[5:9])
-
-	databases, err := parseDatabaseSection(data)
-	if err != nil {
-		return nil, err
-	}
-	parsedRdb.Databases = databases
+const (
+	// maxRDBVersion is the latest dump version the decoder understands
+	maxRDBVersion = 12
+	// maxStringLength mirrors Redis' proto-max-bulk-len, longer strings can only come from a corrupt dump
+	maxStringLength = 512 * 1024 * 1024
+)
 
-	return parsedRdb, nil
-}
+// KeyHandler receives every key decoded from a dump along with the number of the database it belongs to.
+type KeyHandler func(databaseNumber int, key string, val KVValue) error
 
 /*
-	readRDBFile reads a rdb dump from a given path and returns it in the form of a byte array
+	DecodeRdbFile decodes the RDB dump at dirPath/fileName, see DecodeRdb. The file is read as a stream, so the dump is
+	never held in memory as a whole.
 
 	Function Signature:
-		func readRdbFile(dirPath, fileName string) ([]byte, error)
+		func DecodeRdbFile(dirPath, fileName string, handleKey KeyHandler) (*RDBType, error)
 
 	Parameters:
 		- dirPath: Directory where the RDB dump is present. (string)
 		- fileName: Name of the RDB dump. (string)
-	
+		- handleKey: Called with every key that has not expired. (KeyHandler)
+
 	Returns:
-		- []byte - A byte array containing the rdb data.
+		- *RDBType - The version, metadata and databases of the dump, without their keys.
 		- error - Error, if any, else nil.
 
 	Example Usage:
-	  rdbData, err := readRdbFile("xyz/dumps", "dump.rdb")
-	  // Output
-	  rdbData = [5 103 114 97 112 101 0 5 97 112 112 108 101 5 97 112 112 108 101 0 6 98 97 110 97 110 97 9 112 105 110 101 97 112 112 108 101 0 6 111 114 97 110 103 101 10 115 116 114 97 119 98 101 114 114 121 0 9 114 97 115 112 98 101 114 114 121 9 98 108 117 101 98 101 114 114 121 255 227 231 5 135 233 60 120 138 10]
-	  err = nil
+		rdbData, err := DecodeRdbFile("xyz/dumps", "dump.rdb", func(databaseNumber int, key string, val KVValue) error {
+			fmt.Println(databaseNumber, key, val.Value)
+			return nil
+		})
+		// Output 0 foo bar
 */
-func readRdbFile(dirPath, fileName string) ([]byte, error) {
-	path := fileName
-	if dirPath != "" {
-		path = dirPath + "/" + path
-	}
-
-	rdbData, err := os.ReadFile(path)
+func DecodeRdbFile(dirPath, fileName string, handleKey KeyHandler) (*RDBType, error) {
+	rdbFile, err := os.Open(rdbFilePath(dirPath, fileName))
 	if err != nil {
 		return nil, fmt.Errorf("error in reading rdb file: %s", err.Error())
 	}
+	defer rdbFile.Close()
 
-	return rdbData, nil
+	return DecodeRdb(rdbFile, handleKey)
 }
 
 /*
-	validateRdbFile checks if a given rdb byte array is valid or not by performing some basic checks.
+	DecodeRdb reads an RDB dump from a stream opcode by opcode and hands every key to handleKey as soon as it is
+	decoded. Metadata fields and the resize hints of the databases are collected in the returned *RDBType, keys that
+	have already expired are skipped, and the CRC64 checksum following the EOF opcode is verified unless it is 0.
+	When r is a *bufio.Reader of at least 16KB nothing past the checksum is consumed from it, so the dump can be
+	followed by other data, like the commands of an append only file.
 
 	Function Signature:
-		func validateRdbFile(data []byte) (bool, error)
+		func DecodeRdb(r io.Reader, handleKey KeyHandler) (*RDBType, error)
 
 	Parameters:
-		- data: A byte array representation of the rdb dump. ([]byte])
-	
-	Returns:
-		- bool - Whether it is a valid rdb or not.
-		- error - Error, if any, else nil.
-
-	Example Usage:
-		isValid, err := validateRdbFile([5 103 114 97 112 101 0 5 97 112 112 108 101 5 97])
-		// Output isValid = false, err = nil
-*/
-func validateRdbFile(data []byte) (bool, error) {
-	// First 5 bytes must have the 'REDIS' magic string
-	if len(data) < 5 || !bytes.Equal(data[:5], RDBHeader) {
-		return false, fmt.Errorf("malformed rdb file: must start with REDIS header")
-	}
-	// Next 4 bytes should be present which store the version number
-	if len(data) < 9 {
-		return false, fmt.Errorf("malformed rdb file: version is missing")
-	}
-	// EOF indicator should be present
-	if !bytes.Contains(data, []byte{EOFHeader}) {
-		return false, fmt.Errorf("malformed rdb file: EOF missing")
-	}
-	return true, nil
-}
-
-/*
-	parseDatabaseSection takes in a rdb byte array and parses the database sections out of it.
-
-	Function Signature:
-		func parseDatabaseSection(data []byte) ([]RDBDatabase, error)
-
-	Parameters:
-		- data: A byte array representation of the rdb dump. ([]byte])
+		- r: Reader the dump is read from. (io.Reader)
+		- handleKey: Called with every key that has not expired, an error aborts the decoding. (KeyHandler)
 
 	Returns:
-		- []RDBDatabase - The parsed database sections from the dump.
+		- *RDBType - The version, metadata and databases of the dump, without their keys.
 		- error - Error, if any, else nil.
 
 	Example Usage:
-	  rdbDatabases, err := parseDatabaseSection([5 103 114 97 112 101 0 5 97 112 112 108 101 5 97])
-	  // Output
-	  rdbDatabases = [
+		rdbData, err := DecodeRdb(bytes.NewReader(dump), store.LoadKey)
+		// Output
+		rdbData = {
+			Version: "0011",
+			Metadata: {"redis-ver": "7.2.0", "redis-bits": "64"},
+			Databases: [
 				{
 					DatabaseNumber: 0,
 					HashTableSize: 5,
-					ExpiryHashTableSize: 5,
-					KVMap: {
-						"foo": {
-							Value: "bar",
-							ExpireAt: 1729006766003
-						},
-						"appple": {
-							Value: "banana"
-						}
-					}
+					ExpiryHashTableSize: 1,
 				}
-			],
-		error = nil
+			]
+		},
+		err = nil
 */
-func parseDatabaseSection(data []byte) ([]RDBDatabase, error) {
-	if !bytes.Contains(data, []byte{DatabaseHeader}) {
-		return []RDBDatabase{}, nil
+func DecodeRdb(r io.Reader, handleKey KeyHandler) (*RDBType, error) {
+	d := &decoder{rd: bufio.NewReaderSize(r, 16 * 1024)}
+
+	header := d.read(9)
+	if d.err != nil {
+		return nil, fmt.Errorf("malformed rdb file: version is missing")
+	}
+	if !bytes.Equal(header[:5], RDBHeader) {
+		return nil, fmt.Errorf("malformed rdb file: must start with REDIS header")
+	}
+	version, err := strconv.Atoi(string(header[5:9]))
+	if err != nil || version < 1 || version > maxRDBVersion {
+		return nil, fmt.Errorf("malformed rdb file: unsupported version %q", string(header[5:9]))
 	}
-	startIndex := bytes.Index(data, []byte{DatabaseHeader}) + 1
 
-	databases := make([]RDBDatabase, 0)
+	parsedRdb := &RDBType{
+		Version: string(header[5:9]),
+		Metadata: map[string]string{},
+		Databases: []RDBDatabase{},
+	}
+	databaseNumber := 0
+	expiry := uint64(0)
 	for {
-		database := new(RDBDatabase)
-		kvMap := make(map[string]KVValue)
-
-		databaseNumber, bytesConsumed, err := parseSizeEncoding(data[startIndex:])
-		if err != nil {
-			return nil, err
-		}
-		startIndex += bytesConsumed + 1 // + 1 because databseNumber follows 1 byte which has 0xFB indicating hash table size information follows
-
-		hashTableSize, bytesConsumed, _ := parseSizeEncoding(data[startIndex:])
-		if err != nil {
-			return nil, err
+		opcode := d.readByte()
+		if d.err != nil {
+			return nil, d.err
 		}
-		startIndex += bytesConsumed
-
-		expiryHashTableSize, bytesConsumed, _ := parseSizeEncoding(data[startIndex:])
-		if err != nil {
-			return nil, err
-		}
-		startIndex += bytesConsumed
-		
-		database.DatabaseNumber = databaseNumber
-		database.HashTableSize = hashTableSize
-		database.ExpiryHashTableSize = expiryHashTableSize
-
-		for {
-			if data[startIndex] == KeyExpiryHeaderMS || data[startIndex] == KeyExpiryHeaderSec {
-				expiry := uint64(0)
-				if data[startIndex] == KeyExpiryHeaderMS {
-					expiry = binary.LittleEndian.Uint64(data[startIndex + 1:startIndex + 9])
-					startIndex += 9
-					if data[startIndex] != 0 {
-						return databases, fmt.Errorf("only string type is supported")
-					}
-					startIndex++
-				} else {
-					expiry = binary.LittleEndian.Uint64(data[startIndex + 1:startIndex + 5]) * 1000
-					startIndex += 5
-
-					if data[startIndex] != 0 {
-						return databases, fmt.Errorf("only string type is supported")
-					}
-					startIndex++
-				}
 
-				key, bytesConsumed, err := stringEncoding(data[startIndex:])
-				if err != nil {
-					return nil, err
+		switch opcode {
+			case MetadataHeader: {
+				key := d.readString()
+				parsedRdb.Metadata[key] = d.readString()
+			}
+			case DatabaseHeader: {
+				databaseNumber = d.readLength()
+				parsedRdb.Databases = append(parsedRdb.Databases, RDBDatabase{DatabaseNumber: databaseNumber})
+			}
+			case ResizeDBHeader: {
+				if len(parsedRdb.Databases) == 0 {
+					parsedRdb.Databases = append(parsedRdb.Databases, RDBDatabase{DatabaseNumber: databaseNumber})
 				}
-				startIndex += bytesConsumed
-
-				val, bytesConsumed, err := stringEncoding(data[startIndex:])
-				if err != nil {
-					return nil, err
+				database := &parsedRdb.Databases[len(parsedRdb.Databases) - 1]
+				database.HashTableSize = d.readLength()
+				database.ExpiryHashTableSize = d.readLength()
+			}
+			case KeyExpiryHeaderMS: {
+				expiry = binary.LittleEndian.Uint64(d.read(8))
+			}
+			case KeyExpiryHeaderSec: {
+				expiry = uint64(binary.LittleEndian.Uint32(d.read(4))) * 1000
+			}
+			case IdleHeader: {
+				// the LRU idle time of the next key is not kept
+				d.readLength()
+			}
+			case FreqHeader: {
+				// the LFU counter of the next key is not kept
+				d.readByte()
+			}
+			case FunctionHeader: {
+				// functions are not supported, the code of the library is skipped
+				d.readString()
+			}
+			case SlotInfoHeader: {
+				// slot id, slot size and expires slot size are cluster hints
+				d.readLength()
+				d.readLength()
+				d.readLength()
+			}
+			case ModuleAuxHeader: {
+				return nil, fmt.Errorf("malformed rdb file: module auxiliary data is not supported")
+			}
+			case EOFHeader: {
+				if version < 5 {
+					return parsedRdb, nil
 				}
-				startIndex += bytesConsumed
-				
-				if uint64(time.Now().UnixMilli()) < expiry {
-					kvMap[key] = KVValue {
-						Value: val,
-						ExpireAt: expiry,
-					}
+				computed := d.crc
+				expected := binary.LittleEndian.Uint64(d.read(8))
+				if d.err != nil {
+					return nil, d.err
 				}
-				
-			} else {
-				if data[startIndex] != 0 {
-					return databases, fmt.Errorf("only string type is supported")
+				if expected != 0 && expected != computed {
+					return nil, fmt.Errorf("malformed rdb file: checksum mismatch, expected 0x%016x but computed 0x%016x", expected, computed)
 				}
-				startIndex = startIndex + 1
-
-				key, bytesConsumed, err := stringEncoding(data[startIndex:])
-				if err != nil {
-					return nil, err
+				return parsedRdb, nil
+			}
+			default: {
+				key := d.readString()
+				val := d.readValue(opcode)
+				if d.err != nil {
+					return nil, fmt.Errorf("error parsing value of key %s: %s", key, d.err.Error())
 				}
-				startIndex += bytesConsumed
 
-				val, bytesConsumed, err := stringEncoding(data[startIndex:])
-				if err != nil {
-					return nil, err
-				}
-				startIndex += bytesConsumed
-				
-				kvMap[key] = KVValue {
-					Value: val,
+				if expiry == 0 || uint64(time.Now().UnixMilli()) < expiry {
+					val.ExpireAt = expiry
+					if err := handleKey(databaseNumber, key, val); err != nil {
+						return nil, err
+					}
 				}
+				expiry = 0
 			}
+		}
 
-			if startIndex >= len(data) || data[startIndex] == DatabaseHeader || data[startIndex] == EOFHeader {
-				break
-			}
+		if d.err != nil {
+			return nil, d.err
 		}
+	}
+}
 
-		database.KVMap = kvMap
-		databases = append(databases, *database)
+// decoder reads the sections of an RDB dump while computing its checksum, the first error encountered is kept and
+// every later read returns a zero value.
+type decoder struct {
+	rd *bufio.Reader
+	crc uint64
+	err error
+}
 
-		if startIndex >= len(data) || data[startIndex] == DatabaseHeader || data[startIndex] == EOFHeader {
-			break
+// read reads exactly n bytes, a slice of n zero bytes is returned after an error.
+func (d *decoder) read(n int) []byte {
+	if d.err != nil {
+		return make([]byte, n)
+	}
+	data := make([]byte, n)
+	if _, err := io.ReadFull(d.rd, data); err != nil {
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			d.fail(fmt.Errorf("malformed rdb file: unexpected end of file"))
+		} else {
+			d.fail(fmt.Errorf("error in reading rdb file: %s", err.Error()))
 		}
+		return make([]byte, n)
 	}
+	d.crc = crc64Update(d.crc, data)
+	return data
+}
 
-	return databases, nil
+func (d *decoder) readByte() byte {
+	return d.read(1)[0]
 }
 
 /*
-	stringEncoding takes in a rdb byte array and returns a string based on rdb string encoding specification
+	readLength reads a length encoded with the rdb length encoding specification: the 2 most significant bits of the
+	first byte tell if the length is in its remaining 6 bits, in those 6 bits and the next byte, or in the next 4 or 8
+	big endian bytes.
 
 	Function Signature:
-		func stringEncoding(data []byte) (string, int, error)
+		func (d *decoder) readLength() int
 
-	Parameters:
-		- data: A byte array representation of the rdb dump. ([]byte])
-	
 	Returns:
-		- string - The decoded string.
-		- int - The number of bytes consumed.
-		- error - Error, if any, else nil.
+		- int - The length, 0 after an error.
 
 	Example Usage:
-		size, bytesConsumed, err := stringEncoding([5 103 114 97 112 101 0 5 97 112 112 108 101 5 97])
-		// Output size = "abc", bytesConsumed = 1, err = nil
+		length := d.readLength() // reading [66 188]
+		// Output length = 700
 */
-func stringEncoding(data []byte) (string, int, error) {
-    strSize, bytesConsumed, err := parseSizeEncoding(data)
-    if err != nil {
-        return "", 0, fmt.Errorf("error parsing size encoding: %v", err)
-    }
-
-    if bytesConsumed + strSize > len(data) {
-        return "", 0, fmt.Errorf("string size exceeds data length: %d > %d", bytesConsumed + strSize, len(data))
-    }
-
-    // Extract the string based on the parsed size
-    result := string(data[bytesConsumed : bytesConsumed+strSize])
+func (d *decoder) readLength() int {
+	firstByte := d.readByte()
+	if d.err != nil {
+		return 0
+	}
 
-    return result, bytesConsumed + strSize, nil
+	switch firstByte & 0b11000000 {
+		case byte(0b00000000): {
+			// size is in the next 6 bits of first byte
+			return int(firstByte & 0b00111111)
+		}
+		case byte(0b01000000): {
+			// size is in the next 14 bits, last 6 of first byte and all bits from next byte
+			return (int(firstByte & 0b00111111) << 8) | int(d.readByte())
+		}
+		case byte(0b10000000): {
+			// size is in the next 32 bits or 64 bits
+			switch firstByte {
+				case 0x80: {
+					return int(binary.BigEndian.Uint32(d.read(4)))
+				}
+				case 0x81: {
+					return int(binary.BigEndian.Uint64(d.read(8)))
+				}
+			}
+		}
+	}
+	d.fail(fmt.Errorf("unexpected encoding 0x%X in place of a length", firstByte))
+	return 0
 }
 
 /*
-	parseSizeEncoding takes in a rdb byte array and finds the size based on rdb length encoding specification
+	readString reads a string based on rdb string encoding specification. Strings are either prefixed by their length,
+	or use a special encoding: an 8, 16 or 32 bit integer stored in binary, or an LZF compressed string prefixed by
+	its compressed and uncompressed lengths.
 
 	Function Signature:
-		func parseSizeEncoding(data []byte) (int, int, error)
+		func (d *decoder) readString() string
 
-	Parameters:
-		- data: A byte array representation of the rdb dump. ([]byte])
-	
 	Returns:
-		- int - The length / size.
-		- int - The number of bytes consumed.
-		- error - Error, if any, else nil.
+		- string - The decoded string, empty after an error.
 
 	Example Usage:
-		size, bytesConsumed, err := parseSizeEncoding([5 103 114 97 112 101 0 5 97 112 112 108 101 5 97])
-		// Output size = 10, bytesConsumed = 1, err = nil
+		str := d.readString() // reading [3 97 98 99]
+		// Output str = "abc"
+		str := d.readString() // reading [193 57 48]
+		// Output str = "12345"
 */
-func parseSizeEncoding(data []byte) (int, int, error) {
-	firstByte, firstTwoSignificantBits := data[0],  (data[0] & 0b11000000)
-
-	switch firstTwoSignificantBits {
-		case byte(0b00000000): {
-			// size is in the next 6 bits of first byte
-			return int(firstByte & 0b00111111), 1, nil
+func (d *decoder) readString() string {
+	if d.err != nil {
+		return ""
+	}
+	firstByte, err := d.rd.Peek(1)
+	if err != nil || firstByte[0] & 0b11000000 != 0b11000000 {
+		length := d.readLength()
+		if length < 0 || length > maxStringLength {
+			d.fail(fmt.Errorf("string length %d exceeds the maximum of %d", length, maxStringLength))
+			return ""
 		}
-		case byte(0b01000000): {
-			// size is in the next 14 bits, last 6 of first byte and all bits from next byte
-			if len(data) < 2 {
-        		return -1, 0, fmt.Errorf("insufficient data for 14-bit size")
-    		}
+		return string(d.read(length))
+	}
 
-			secondByte := data[1]
-			return int(((firstByte & 0b00111111) << 8) | secondByte), 2, nil
+	encoding := d.readByte() & 0b00111111
+	switch encoding {
+		case 0: {
+			// 8-bit integer encoding
+			return strconv.FormatInt(int64(int8(d.readByte())), 10)
 		}
-		case byte(0b10000000): {
-			// Size is in the next 32 bits or 64 bits (ignore remaining 6 bits of the first byte)
-			if len(data) < 5 {
-				return -1, 0, fmt.Errorf("insufficient data for 32-bit size")
+		case 1: {
+			// 16-bit integer encoding (little-endian)
+			return strconv.FormatInt(int64(int16(binary.LittleEndian.Uint16(d.read(2)))), 10)
+		}
+		case 2: {
+			// 32-bit integer encoding (little-endian)
+			return strconv.FormatInt(int64(int32(binary.LittleEndian.Uint32(d.read(4)))), 10)
+		}
+		case 3: {
+			// LZF compressed string: compressed length, uncompressed length and the compressed data
+			compressedLength := d.readLength()
+			length := d.readLength()
+			if compressedLength < 0 || compressedLength > maxStringLength || length < 0 || length > maxStringLength {
+				d.fail(fmt.Errorf("lzf compressed string exceeds the maximum length of %d", maxStringLength))
+				return ""
 			}
-
-			if byte(firstByte) == byte(0x80) {
-				size := int(binary.BigEndian.Uint32(data[1:5]))
-				return size, 5, nil
-			} else {
-				size := int(binary.BigEndian.Uint64(data[1:9]))
-				return size, 9, nil
+			compressed := d.read(compressedLength)
+			if d.err != nil {
+				return ""
 			}
-			
-		}
-		case byte(0b11000000): {
-			// Handle special string encodings based on remaining 6 bits
-			switch int(firstByte & 0b00111111) {
-				case 0: {
-					// 8-bit integer encoding
-					return int(data[1]), 2, nil
-				}
-				case 1: {
-					// 16-bit integer encoding (little-endian)
-					if len(data) < 3 {
-						return -1, 0, fmt.Errorf("insufficient data for 16-bit integer")
-					}
-					return int(binary.LittleEndian.Uint16(data[1:3])), 3, nil
-				}
-				case 2: {
-					// 32-bit integer encoding (little-endian)
-					if len(data) < 5 {
-						return -1, 0, fmt.Errorf("insufficient data for 32-bit integer")
-					}
-					return int(binary.LittleEndian.Uint32(data[1:5])), 5, nil
-				}
-				case 3: {
-					// LZF compression not supported
-					return -1, 0, fmt.Errorf("lzf compression is not supported")
-				}
-				default: {
-					return -1, 0, fmt.Errorf("unknown string encoding: 0x%X", firstByte)
-				}
+			decompressed, err := lzfDecompress(compressed, length)
+			if err != nil {
+				d.fail(err)
+				return ""
 			}
-
+			return string(decompressed)
 		}
+		default: {
+			d.fail(fmt.Errorf("unknown string encoding: 0x%X", 0b11000000 | encoding))
+			return ""
+		}
+	}
+}
+
+// readStreamID reads the two parts of a stream ID, both encoded as lengths.
+func (d *decoder) readStreamID() StreamID {
+	return StreamID{Ms: uint64(d.readLength()), Seq: uint64(d.readLength())}
+}
+
+// fail keeps err unless an earlier error is already kept.
+func (d *decoder) fail(err error) {
+	if d.err == nil {
+		d.err = err
 	}
-	return -1, 0, fmt.Errorf("error occurred while parsing size encoding")
 }
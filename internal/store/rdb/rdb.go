@@ -1,7 +1,84 @@
 package rdb
 
+// ValueType is the type of the value held by a key.
+type ValueType int
+const (
+	StringType ValueType = iota
+	ListType
+	SetType
+	ZSetType
+	HashType
+	StreamType
+)
+
+// String returns the name of the type as reported by the TYPE command.
+func (valueType ValueType) String() string {
+	switch valueType {
+		case StringType: {
+			return "string"
+		}
+		case ListType: {
+			return "list"
+		}
+		case SetType: {
+			return "set"
+		}
+		case ZSetType: {
+			return "zset"
+		}
+		case HashType: {
+			return "hash"
+		}
+		case StreamType: {
+			return "stream"
+		}
+		default: {
+			return "none"
+		}
+	}
+}
+
+type ZSetMember struct {
+	Member string;
+	Score float64;
+}
+
+type StreamID struct {
+	Ms uint64;
+	Seq uint64;
+}
+
+type StreamEntry struct {
+	ID StreamID;
+	// Fields holds the field names and values of the entry alternately
+	Fields []string;
+}
+
+type StreamGroup struct {
+	Name string;
+	LastID StreamID;
+	EntriesRead int64;
+}
+
+type Stream struct {
+	Entries []StreamEntry;
+	Length uint64;
+	LastID StreamID;
+	FirstID StreamID;
+	MaxDeletedID StreamID;
+	EntriesAdded uint64;
+	Groups []StreamGroup;
+}
+
+// KVValue is the value of a key, only the field matching its Type is set.
 type KVValue struct {
+	Type ValueType;
 	Value string;
+	List []string;
+	Set []string;
+	ZSet []ZSetMember;
+	Hash map[string]string;
+	Stream *Stream;
 	ExpireAt uint64;
 }
 type RDBDatabase struct {
@@ -12,5 +89,7 @@ type RDBDatabase struct {
 }
 type RDBType struct {
 	Version string;
+	// Metadata holds the auxiliary fields of a decoded dump
+	Metadata map[string]string;
 	Databases []RDBDatabase;
-}
\ No newline at end of file
+}
@@ -0,0 +1,801 @@
+package rdb
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math"
+	"strconv"
+)
+
+// value type indicators preceding every key
+const (
+	TypeString = byte(0)
+	TypeList = byte(1)
+	TypeSet = byte(2)
+	TypeZSet = byte(3)
+	TypeHash = byte(4)
+	TypeZSet2 = byte(5)
+	TypeHashZipmap = byte(9)
+	TypeListZiplist = byte(10)
+	TypeSetIntset = byte(11)
+	TypeZSetZiplist = byte(12)
+	TypeHashZiplist = byte(13)
+	TypeListQuicklist = byte(14)
+	TypeStreamListpacks = byte(15)
+	TypeHashListpack = byte(16)
+	TypeZSetListpack = byte(17)
+	TypeListQuicklist2 = byte(18)
+	TypeStreamListpacks2 = byte(19)
+	TypeSetListpack = byte(20)
+	TypeStreamListpacks3 = byte(21)
+)
+
+// quicklist node containers
+const (
+	quicklistNodePlain = 1
+	quicklistNodePacked = 2
+)
+
+// stream listpack entry flags
+const (
+	streamItemFlagDeleted = 1
+	streamItemFlagSameFields = 2
+)
+
+/*
+	readValue reads the value of a key of the given type, including the compact ziplist, listpack, intset, zipmap and
+	quicklist encodings.
+
+	Function Signature:
+		func (d *decoder) readValue(valueType byte) KVValue
+
+	Parameters:
+		- valueType: The value type indicator that preceded the key. (byte)
+
+	Returns:
+		- KVValue - The decoded value without its expiry, the error is kept in the decoder.
+
+	Example Usage:
+		val := d.readValue(TypeList) // reading [2 1 97 1 98]
+		// Output val = {Type: ListType, List: ["a", "b"]}
+*/
+func (d *decoder) readValue(valueType byte) KVValue {
+	switch valueType {
+		case TypeString: {
+			return KVValue{Type: StringType, Value: d.readString()}
+		}
+		case TypeList: {
+			return KVValue{Type: ListType, List: d.readStringSequence(1)}
+		}
+		case TypeSet: {
+			return KVValue{Type: SetType, Set: d.readStringSequence(1)}
+		}
+		case TypeHash: {
+			return KVValue{Type: HashType, Hash: pairsToHash(d.readStringSequence(2))}
+		}
+		case TypeZSet, TypeZSet2: {
+			return KVValue{Type: ZSetType, ZSet: d.readZSet(valueType == TypeZSet2)}
+		}
+		case TypeHashZipmap, TypeListZiplist, TypeSetIntset, TypeZSetZiplist, TypeHashZiplist, TypeHashListpack, TypeZSetListpack, TypeSetListpack: {
+			blob := d.readString()
+			if d.err != nil {
+				return KVValue{}
+			}
+			val, err := parseEncodedBlob(valueType, []byte(blob))
+			if err != nil {
+				d.fail(err)
+			}
+			return val
+		}
+		case TypeListQuicklist, TypeListQuicklist2: {
+			return KVValue{Type: ListType, List: d.readQuicklist(valueType == TypeListQuicklist2)}
+		}
+		case TypeStreamListpacks, TypeStreamListpacks2, TypeStreamListpacks3: {
+			return KVValue{Type: StreamType, Stream: d.readStream(valueType)}
+		}
+		default: {
+			d.fail(fmt.Errorf("unsupported value type: %d", valueType))
+			return KVValue{}
+		}
+	}
+}
+
+// parseEncodedBlob decodes a value stored as a single ziplist, listpack, intset or zipmap blob.
+func parseEncodedBlob(valueType byte, blob []byte) (KVValue, error) {
+	var elems []string
+	var err error
+	switch valueType {
+		case TypeHashZipmap: {
+			hash, err := parseZipmap(blob)
+			return KVValue{Type: HashType, Hash: hash}, err
+		}
+		case TypeSetIntset: {
+			elems, err = parseIntset(blob)
+			return KVValue{Type: SetType, Set: elems}, err
+		}
+		case TypeListZiplist, TypeZSetZiplist, TypeHashZiplist: {
+			elems, err = parseZiplist(blob)
+		}
+		default: {
+			elems, err = parseListpack(blob)
+		}
+	}
+	if err != nil {
+		return KVValue{}, err
+	}
+
+	switch valueType {
+		case TypeListZiplist: {
+			return KVValue{Type: ListType, List: elems}, nil
+		}
+		case TypeSetListpack: {
+			return KVValue{Type: SetType, Set: elems}, nil
+		}
+		case TypeZSetZiplist, TypeZSetListpack: {
+			zset, err := pairsToZSet(elems)
+			return KVValue{Type: ZSetType, ZSet: zset}, err
+		}
+		default: {
+			if len(elems) % 2 != 0 {
+				return KVValue{}, fmt.Errorf("hash has a field without a value")
+			}
+			return KVValue{Type: HashType, Hash: pairsToHash(elems)}, nil
+		}
+	}
+}
+
+// readStringSequence reads a length followed by length * stride strings.
+func (d *decoder) readStringSequence(stride int) []string {
+	length := d.readLength() * stride
+	elems := make([]string, 0, capacityHint(length))
+	for i := 0; i < length && d.err == nil; i++ {
+		elems = append(elems, d.readString())
+	}
+	return elems
+}
+
+// readZSet reads a sorted set, binaryScores tells if scores are stored as 8 byte doubles instead of strings.
+func (d *decoder) readZSet(binaryScores bool) []ZSetMember {
+	length := d.readLength()
+	zset := make([]ZSetMember, 0, capacityHint(length))
+	for i := 0; i < length && d.err == nil; i++ {
+		member := d.readString()
+		zset = append(zset, ZSetMember{Member: member, Score: d.readScore(binaryScores)})
+	}
+	return zset
+}
+
+// readScore reads a sorted set score, either an 8 byte little endian double or a string prefixed by its length
+// where the lengths 253, 254 and 255 stand for nan, +inf and -inf.
+func (d *decoder) readScore(binaryScore bool) float64 {
+	if binaryScore {
+		return math.Float64frombits(binary.LittleEndian.Uint64(d.read(8)))
+	}
+
+	length := d.readByte()
+	switch length {
+		case 253: {
+			return math.NaN()
+		}
+		case 254: {
+			return math.Inf(1)
+		}
+		case 255: {
+			return math.Inf(-1)
+		}
+	}
+	str := string(d.read(int(length)))
+	if d.err != nil {
+		return 0
+	}
+	score, err := strconv.ParseFloat(str, 64)
+	if err != nil {
+		d.fail(fmt.Errorf("invalid score: %s", str))
+	}
+	return score
+}
+
+// readQuicklist reads a list stored as a sequence of ziplist nodes, or of listpack and plain nodes for quicklist 2.
+func (d *decoder) readQuicklist(version2 bool) []string {
+	numNodes := d.readLength()
+	list := []string{}
+	for i := 0; i < numNodes && d.err == nil; i++ {
+		container := quicklistNodePacked
+		if version2 {
+			container = d.readLength()
+			if container != quicklistNodePlain && container != quicklistNodePacked {
+				d.fail(fmt.Errorf("unknown quicklist node container: %d", container))
+			}
+		}
+
+		node := d.readString()
+		if d.err != nil {
+			break
+		}
+		if container == quicklistNodePlain {
+			list = append(list, node)
+			continue
+		}
+
+		var elems []string
+		var err error
+		if version2 {
+			elems, err = parseListpack([]byte(node))
+		} else {
+			elems, err = parseZiplist([]byte(node))
+		}
+		if err != nil {
+			d.fail(err)
+		}
+		list = append(list, elems...)
+	}
+	return list
+}
+
+/*
+	readStream reads a stream: its listpacks of entries, its metadata and its consumer groups. Pending entries and
+	consumers of the groups are skipped.
+
+	Function Signature:
+		func (d *decoder) readStream(valueType byte) *Stream
+
+	Parameters:
+		- valueType: One of the three stream value types, later versions store more metadata. (byte)
+
+	Returns:
+		- *Stream - The decoded stream, the error is kept in the decoder.
+*/
+func (d *decoder) readStream(valueType byte) *Stream {
+	stream := &Stream{}
+
+	numListpacks := d.readLength()
+	for i := 0; i < numListpacks && d.err == nil; i++ {
+		masterKey := d.readString()
+		listpack := d.readString()
+		if d.err != nil {
+			break
+		}
+		if len(masterKey) != 16 {
+			d.fail(fmt.Errorf("stream node key is not a valid ID"))
+			break
+		}
+		masterID := StreamID{
+			Ms: binary.BigEndian.Uint64([]byte(masterKey[:8])),
+			Seq: binary.BigEndian.Uint64([]byte(masterKey[8:])),
+		}
+		entries, err := parseStreamListpack(masterID, []byte(listpack))
+		if err != nil {
+			d.fail(err)
+			break
+		}
+		stream.Entries = append(stream.Entries, entries...)
+	}
+
+	stream.Length = uint64(d.readLength())
+	stream.LastID = d.readStreamID()
+	if valueType >= TypeStreamListpacks2 {
+		stream.FirstID = d.readStreamID()
+		stream.MaxDeletedID = d.readStreamID()
+		stream.EntriesAdded = uint64(d.readLength())
+	} else {
+		stream.EntriesAdded = stream.Length
+	}
+
+	numGroups := d.readLength()
+	for i := 0; i < numGroups && d.err == nil; i++ {
+		group := StreamGroup{EntriesRead: -1}
+		group.Name = d.readString()
+		group.LastID = d.readStreamID()
+		if valueType >= TypeStreamListpacks2 {
+			group.EntriesRead = int64(d.readLength())
+		}
+
+		// pending entries: raw ID, delivery time and delivery count
+		numPending := d.readLength()
+		for j := 0; j < numPending && d.err == nil; j++ {
+			d.read(16 + 8)
+			d.readLength()
+		}
+		// consumers: name, seen time, active time and the IDs of their pending entries
+		numConsumers := d.readLength()
+		for j := 0; j < numConsumers && d.err == nil; j++ {
+			d.readString()
+			d.read(8)
+			if valueType >= TypeStreamListpacks3 {
+				d.read(8)
+			}
+			numConsumerPending := d.readLength()
+			for k := 0; k < numConsumerPending && d.err == nil; k++ {
+				d.read(16)
+			}
+		}
+		stream.Groups = append(stream.Groups, group)
+	}
+	return stream
+}
+
+// parseStreamListpack decodes the entries of a stream listpack. The first entries of the listpack form the master
+// entry: count, deleted count, the number of master fields, the master fields and a 0 terminator. Each entry follows
+// as flags, ms and seq offsets from the master ID, its values (and fields unless it has the same fields as the master
+// entry) and finally its number of listpack elements.
+func parseStreamListpack(masterID StreamID, listpack []byte) ([]StreamEntry, error) {
+	elems, err := parseListpack(listpack)
+	if err != nil {
+		return nil, err
+	}
+	cursor := &elemCursor{elems: elems}
+
+	cursor.readInt() // count
+	cursor.readInt() // deleted
+	numMasterFields := int(cursor.readInt())
+	masterFields := make([]string, 0, numMasterFields)
+	for i := 0; i < numMasterFields; i++ {
+		masterFields = append(masterFields, cursor.read())
+	}
+	cursor.read() // master entry terminator
+
+	entries := []StreamEntry{}
+	for cursor.err == nil && cursor.pos < len(elems) {
+		flags := cursor.readInt()
+		entry := StreamEntry{
+			ID: StreamID{
+				Ms: masterID.Ms + uint64(cursor.readInt()),
+				Seq: masterID.Seq + uint64(cursor.readInt()),
+			},
+		}
+		if flags & streamItemFlagSameFields != 0 {
+			for _, field := range masterFields {
+				entry.Fields = append(entry.Fields, field, cursor.read())
+			}
+		} else {
+			numFields := int(cursor.readInt())
+			for i := 0; i < numFields; i++ {
+				entry.Fields = append(entry.Fields, cursor.read(), cursor.read())
+			}
+		}
+		cursor.read() // number of listpack elements of the entry
+
+		if flags & streamItemFlagDeleted == 0 {
+			entries = append(entries, entry)
+		}
+	}
+	if cursor.err != nil {
+		return nil, fmt.Errorf("malformed stream listpack: %s", cursor.err.Error())
+	}
+	return entries, nil
+}
+
+/*
+	parseZiplist decodes the entries of a ziplist blob. A ziplist starts with its total bytes (4 bytes), the offset of
+	its last entry (4 bytes) and its number of entries (2 bytes), each entry is made of the length of the previous
+	entry, an encoding and the data, and the ziplist ends with 0xFF.
+
+	Function Signature:
+		func parseZiplist(blob []byte) ([]string, error)
+
+	Parameters:
+		- blob: The ziplist. ([]byte)
+
+	Returns:
+		- []string - The entries, integers are returned in their decimal form.
+		- error - Error, if any, else nil.
+
+	Example Usage:
+		elems, err := parseZiplist([16 0 0 0 13 0 0 0 2 0 0 1 97 3 242 255])
+		// Output elems = ["a", "1"], err = nil
+*/
+func parseZiplist(blob []byte) ([]string, error) {
+	if len(blob) < 11 {
+		return nil, fmt.Errorf("ziplist is too short")
+	}
+
+	elems := []string{}
+	idx := 10
+	for {
+		if idx >= len(blob) {
+			return nil, fmt.Errorf("ziplist is not terminated")
+		}
+		if blob[idx] == 0xFF {
+			return elems, nil
+		}
+
+		// length of the previous entry
+		if blob[idx] < 254 {
+			idx++
+		} else {
+			idx += 5
+		}
+		if idx >= len(blob) {
+			return nil, fmt.Errorf("ziplist entry is truncated")
+		}
+
+		encoding := blob[idx]
+		var length int
+		switch encoding >> 6 {
+			case 0: {
+				length = int(encoding & 0x3F)
+				idx++
+			}
+			case 1: {
+				if idx + 2 > len(blob) {
+					return nil, fmt.Errorf("ziplist entry is truncated")
+				}
+				length = int(encoding & 0x3F) << 8 | int(blob[idx + 1])
+				idx += 2
+			}
+			case 2: {
+				if idx + 5 > len(blob) {
+					return nil, fmt.Errorf("ziplist entry is truncated")
+				}
+				length = int(binary.BigEndian.Uint32(blob[idx + 1:idx + 5]))
+				idx += 5
+			}
+			default: {
+				num, size, err := ziplistInteger(encoding, blob[idx + 1:])
+				if err != nil {
+					return nil, err
+				}
+				elems = append(elems, strconv.FormatInt(num, 10))
+				idx += 1 + size
+				continue
+			}
+		}
+
+		if idx + length > len(blob) {
+			return nil, fmt.Errorf("ziplist entry is truncated")
+		}
+		elems = append(elems, string(blob[idx:idx + length]))
+		idx += length
+	}
+}
+
+// ziplistInteger decodes an integer ziplist entry and returns it along with the number of data bytes it uses.
+func ziplistInteger(encoding byte, data []byte) (int64, int, error) {
+	size := 0
+	switch encoding {
+		case 0xC0: {
+			size = 2
+		}
+		case 0xD0: {
+			size = 4
+		}
+		case 0xE0: {
+			size = 8
+		}
+		case 0xF0: {
+			size = 3
+		}
+		case 0xFE: {
+			size = 1
+		}
+		default: {
+			if encoding >= 0xF1 && encoding <= 0xFD {
+				// 4 bit immediate integer between 0 and 12
+				return int64(encoding & 0x0F) - 1, 0, nil
+			}
+			return 0, 0, fmt.Errorf("unknown ziplist encoding: 0x%X", encoding)
+		}
+	}
+	if len(data) < size {
+		return 0, 0, fmt.Errorf("ziplist entry is truncated")
+	}
+	return littleEndianInt(data[:size]), size, nil
+}
+
+/*
+	parseListpack decodes the elements of a listpack blob. A listpack starts with its total bytes (4 bytes) and its
+	number of elements (2 bytes), each element is made of an encoding, the data and a backward length, and the
+	listpack ends with 0xFF.
+
+	Function Signature:
+		func parseListpack(blob []byte) ([]string, error)
+
+	Parameters:
+		- blob: The listpack. ([]byte)
+
+	Returns:
+		- []string - The elements, integers are returned in their decimal form.
+		- error - Error, if any, else nil.
+
+	Example Usage:
+		elems, err := parseListpack([12 0 0 0 2 0 129 97 2 1 1 255])
+		// Output elems = ["a", "1"], err = nil
+*/
+func parseListpack(blob []byte) ([]string, error) {
+	if len(blob) < 7 {
+		return nil, fmt.Errorf("listpack is too short")
+	}
+
+	elems := []string{}
+	idx := 6
+	for {
+		if idx >= len(blob) {
+			return nil, fmt.Errorf("listpack is not terminated")
+		}
+		encoding := blob[idx]
+		if encoding == 0xFF {
+			return elems, nil
+		}
+
+		var elem string
+		var entryLength int
+		switch {
+			case encoding & 0x80 == 0: {
+				// 7 bit unsigned integer
+				elem = strconv.Itoa(int(encoding & 0x7F))
+				entryLength = 1
+			}
+			case encoding & 0xC0 == 0x80: {
+				// string with a 6 bit length
+				length := int(encoding & 0x3F)
+				if idx + 1 + length > len(blob) {
+					return nil, fmt.Errorf("listpack element is truncated")
+				}
+				elem = string(blob[idx + 1:idx + 1 + length])
+				entryLength = 1 + length
+			}
+			case encoding & 0xE0 == 0xC0: {
+				// 13 bit signed integer
+				if idx + 2 > len(blob) {
+					return nil, fmt.Errorf("listpack element is truncated")
+				}
+				num := int(encoding & 0x1F) << 8 | int(blob[idx + 1])
+				if num >= 1 << 12 {
+					num -= 1 << 13
+				}
+				elem = strconv.Itoa(num)
+				entryLength = 2
+			}
+			case encoding & 0xF0 == 0xE0: {
+				// string with a 12 bit length
+				if idx + 2 > len(blob) {
+					return nil, fmt.Errorf("listpack element is truncated")
+				}
+				length := int(encoding & 0x0F) << 8 | int(blob[idx + 1])
+				if idx + 2 + length > len(blob) {
+					return nil, fmt.Errorf("listpack element is truncated")
+				}
+				elem = string(blob[idx + 2:idx + 2 + length])
+				entryLength = 2 + length
+			}
+			case encoding == 0xF0: {
+				// string with a 32 bit length
+				if idx + 5 > len(blob) {
+					return nil, fmt.Errorf("listpack element is truncated")
+				}
+				length := int(binary.LittleEndian.Uint32(blob[idx + 1:idx + 5]))
+				if idx + 5 + length > len(blob) {
+					return nil, fmt.Errorf("listpack element is truncated")
+				}
+				elem = string(blob[idx + 5:idx + 5 + length])
+				entryLength = 5 + length
+			}
+			case encoding >= 0xF1 && encoding <= 0xF4: {
+				// 16, 24, 32 or 64 bit signed integer
+				size := map[byte]int{0xF1: 2, 0xF2: 3, 0xF3: 4, 0xF4: 8}[encoding]
+				if idx + 1 + size > len(blob) {
+					return nil, fmt.Errorf("listpack element is truncated")
+				}
+				elem = strconv.FormatInt(littleEndianInt(blob[idx + 1:idx + 1 + size]), 10)
+				entryLength = 1 + size
+			}
+			default: {
+				return nil, fmt.Errorf("unknown listpack encoding: 0x%X", encoding)
+			}
+		}
+
+		elems = append(elems, elem)
+		idx += entryLength + listpackBacklenSize(entryLength)
+	}
+}
+
+// listpackBacklenSize returns the number of bytes used by the backward length of a listpack element.
+func listpackBacklenSize(entryLength int) int {
+	switch {
+		case entryLength <= 127: {
+			return 1
+		}
+		case entryLength < 16383: {
+			return 2
+		}
+		case entryLength < 2097151: {
+			return 3
+		}
+		case entryLength < 268435455: {
+			return 4
+		}
+		default: {
+			return 5
+		}
+	}
+}
+
+/*
+	parseIntset decodes the integers of an intset blob. An intset starts with the size of its integers (4 bytes: 2, 4
+	or 8) and their count (4 bytes), followed by the sorted little endian integers.
+
+	Function Signature:
+		func parseIntset(blob []byte) ([]string, error)
+
+	Parameters:
+		- blob: The intset. ([]byte)
+
+	Returns:
+		- []string - The integers in their decimal form.
+		- error - Error, if any, else nil.
+
+	Example Usage:
+		elems, err := parseIntset([2 0 0 0 2 0 0 0 1 0 2 0])
+		// Output elems = ["1", "2"], err = nil
+*/
+func parseIntset(blob []byte) ([]string, error) {
+	if len(blob) < 8 {
+		return nil, fmt.Errorf("intset is too short")
+	}
+	size := int(binary.LittleEndian.Uint32(blob[:4]))
+	length := int(binary.LittleEndian.Uint32(blob[4:8]))
+	if size != 2 && size != 4 && size != 8 {
+		return nil, fmt.Errorf("unknown intset encoding: %d", size)
+	}
+	if len(blob) < 8 + size * length {
+		return nil, fmt.Errorf("intset is truncated")
+	}
+
+	elems := make([]string, 0, length)
+	for i := 0; i < length; i++ {
+		offset := 8 + i * size
+		elems = append(elems, strconv.FormatInt(littleEndianInt(blob[offset:offset + size]), 10))
+	}
+	return elems, nil
+}
+
+/*
+	parseZipmap decodes the fields and values of a zipmap blob, the hash encoding used before ziplists. A zipmap starts
+	with its number of entries (1 byte), each entry is made of the field length, the field, the value length, a count
+	of free bytes, the value and the free bytes, and the zipmap ends with 0xFF. Lengths are 1 byte, or 254 followed
+	by a 4 byte length.
+
+	Function Signature:
+		func parseZipmap(blob []byte) (map[string]string, error)
+
+	Parameters:
+		- blob: The zipmap. ([]byte)
+
+	Returns:
+		- map[string]string - The fields and their values.
+		- error - Error, if any, else nil.
+
+	Example Usage:
+		hash, err := parseZipmap([1 1 97 1 0 98 255])
+		// Output hash = {"a": "b"}, err = nil
+*/
+func parseZipmap(blob []byte) (map[string]string, error) {
+	hash := map[string]string{}
+	idx := 1
+	readLength := func() (int, error) {
+		if idx >= len(blob) {
+			return 0, fmt.Errorf("zipmap is truncated")
+		}
+		if blob[idx] < 254 {
+			idx++
+			return int(blob[idx - 1]), nil
+		}
+		if blob[idx] == 254 && idx + 5 <= len(blob) {
+			idx += 5
+			return int(binary.LittleEndian.Uint32(blob[idx - 4:idx])), nil
+		}
+		return 0, fmt.Errorf("invalid zipmap length")
+	}
+
+	for {
+		if idx >= len(blob) {
+			return nil, fmt.Errorf("zipmap is not terminated")
+		}
+		if blob[idx] == 0xFF {
+			return hash, nil
+		}
+
+		fieldLength, err := readLength()
+		if err != nil {
+			return nil, err
+		}
+		if idx + fieldLength > len(blob) {
+			return nil, fmt.Errorf("zipmap is truncated")
+		}
+		field := string(blob[idx:idx + fieldLength])
+		idx += fieldLength
+
+		valueLength, err := readLength()
+		if err != nil {
+			return nil, err
+		}
+		if idx >= len(blob) {
+			return nil, fmt.Errorf("zipmap is truncated")
+		}
+		free := int(blob[idx])
+		idx++
+		if idx + valueLength + free > len(blob) {
+			return nil, fmt.Errorf("zipmap is truncated")
+		}
+		hash[field] = string(blob[idx:idx + valueLength])
+		idx += valueLength + free
+	}
+}
+
+// capacityHint bounds the capacity preallocated for a collection, so a corrupt length can not exhaust the memory.
+func capacityHint(length int) int {
+	if length < 0 {
+		return 0
+	}
+	if length > 1024 {
+		return 1024
+	}
+	return length
+}
+
+// pairsToHash converts alternating fields and values into a hash.
+func pairsToHash(elems []string) map[string]string {
+	hash := make(map[string]string, len(elems) / 2)
+	for i := 0; i + 1 < len(elems); i += 2 {
+		hash[elems[i]] = elems[i + 1]
+	}
+	return hash
+}
+
+// pairsToZSet converts alternating members and scores into a sorted set.
+func pairsToZSet(elems []string) ([]ZSetMember, error) {
+	if len(elems) % 2 != 0 {
+		return nil, fmt.Errorf("sorted set has a member without a score")
+	}
+	zset := make([]ZSetMember, 0, len(elems) / 2)
+	for i := 0; i < len(elems); i += 2 {
+		score, err := strconv.ParseFloat(elems[i + 1], 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid score: %s", elems[i + 1])
+		}
+		zset = append(zset, ZSetMember{Member: elems[i], Score: score})
+	}
+	return zset, nil
+}
+
+// littleEndianInt decodes a signed little endian integer of 1 to 8 bytes.
+func littleEndianInt(data []byte) int64 {
+	var num uint64
+	for i := len(data) - 1; i >= 0; i-- {
+		num = num << 8 | uint64(data[i])
+	}
+	// sign extend integers shorter than 8 bytes
+	shift := uint(64 - 8 * len(data))
+	return int64(num << shift) >> shift
+}
+
+// elemCursor reads the elements of a decoded listpack sequentially, the first error is kept.
+type elemCursor struct {
+	elems []string
+	pos int
+	err error
+}
+
+func (c *elemCursor) read() string {
+	if c.err != nil {
+		return ""
+	}
+	if c.pos >= len(c.elems) {
+		c.err = fmt.Errorf("unexpected end of listpack")
+		return ""
+	}
+	c.pos++
+	return c.elems[c.pos - 1]
+}
+
+func (c *elemCursor) readInt() int64 {
+	elem := c.read()
+	if c.err != nil {
+		return 0
+	}
+	num, err := strconv.ParseInt(elem, 10, 64)
+	if err != nil {
+		c.err = fmt.Errorf("expected an integer, found %q", elem)
+	}
+	return num
+}
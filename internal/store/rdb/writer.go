@@ -0,0 +1,501 @@
+package rdb
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// common RDB section indicators used only while writing
+var (
+	RDBVersion = []byte("0011")
+)
+
+// metadata written to the auxiliary fields of every RDB file
+const (
+	redisVersion = "7.2.0"
+	redisBits = "64"
+)
+
+/*
+	WriteRdbFile encodes a *RDBType into an RDB dump and atomically replaces the file at the given path with it.
+	The dump is first written to a temporary file in the same directory, which is then synced and renamed, so a
+	crash while saving never leaves a truncated dump behind.
+
+	Function Signature:
+		func WriteRdbFile(dirPath, fileName string, rdbData *RDBType, usedMemory uint64) error
+
+	Parameters:
+		- dirPath: Directory where the RDB dump is written. (string)
+		- fileName: Name of the RDB dump. (string)
+		- rdbData: The databases to write. (*RDBType)
+		- usedMemory: Memory used by the server, stored in the used-mem metadata. (uint64)
+
+	Returns:
+		- error - Error, if any, else nil.
+
+	Example Usage:
+		err := WriteRdbFile("xyz/dumps", "dump.rdb", &RDBType{Databases: databases}, 1048576)
+		// Output err = nil
+*/
+func WriteRdbFile(dirPath, fileName string, rdbData *RDBType, usedMemory uint64) error {
+	path := rdbFilePath(dirPath, fileName)
+
+	tempFile, err := os.CreateTemp(filepath.Dir(path), fmt.Sprintf("temp-%d-*.rdb", os.Getpid()))
+	if err != nil {
+		return fmt.Errorf("error in creating temporary rdb file: %s", err.Error())
+	}
+	defer os.Remove(tempFile.Name())
+	defer tempFile.Close()
+
+	writer := bufio.NewWriter(tempFile)
+	if err := EncodeRdb(writer, rdbData, usedMemory); err != nil {
+		return err
+	}
+	if err := writer.Flush(); err != nil {
+		return fmt.Errorf("error in writing rdb file: %s", err.Error())
+	}
+	if err := tempFile.Sync(); err != nil {
+		return fmt.Errorf("error in syncing rdb file: %s", err.Error())
+	}
+	if err := tempFile.Close(); err != nil {
+		return fmt.Errorf("error in closing rdb file: %s", err.Error())
+	}
+
+	if err := os.Rename(tempFile.Name(), path); err != nil {
+		return fmt.Errorf("error in renaming temporary rdb file: %s", err.Error())
+	}
+	return nil
+}
+
+/*
+	EncodeRdb writes the RDB encoding of a *RDBType to a writer: the REDIS header and version, the metadata section,
+	every database with its resize hints, the keys with their expiries and finally the EOF marker followed by the
+	CRC64 checksum of everything before it.
+
+	Function Signature:
+		func EncodeRdb(w io.Writer, rdbData *RDBType, usedMemory uint64) error
+
+	Parameters:
+		- w: Writer receiving the dump. (io.Writer)
+		- rdbData: The databases to encode. (*RDBType)
+		- usedMemory: Memory used by the server, stored in the used-mem metadata. (uint64)
+
+	Returns:
+		- error - Error, if any, else nil.
+
+	Example Usage:
+		var buffer bytes.Buffer
+		err := EncodeRdb(&buffer, &RDBType{Databases: databases}, 1048576)
+		// Output buffer = [82 69 68 73 83 48 48 49 49 250 9 114 101 100 ...], err = nil
+*/
+func EncodeRdb(w io.Writer, rdbData *RDBType, usedMemory uint64) error {
+	checksum := &crc64Writer{}
+	encoder := &rdbEncoder{w: io.MultiWriter(w, checksum)}
+
+	encoder.write(RDBHeader)
+	encoder.write(RDBVersion)
+
+	encoder.writeMetadata("redis-ver", redisVersion)
+	encoder.writeMetadata("redis-bits", redisBits)
+	encoder.writeMetadata("ctime", strconv.FormatInt(time.Now().Unix(), 10))
+	encoder.writeMetadata("used-mem", strconv.FormatUint(usedMemory, 10))
+	encoder.writeMetadata("aof-base", "0")
+
+	databases := append([]RDBDatabase{}, rdbData.Databases...)
+	sort.Slice(databases, func(i, j int) bool {
+		return databases[i].DatabaseNumber < databases[j].DatabaseNumber
+	})
+	for _, database := range databases {
+		if len(database.KVMap) == 0 {
+			continue
+		}
+		encoder.writeDatabase(database)
+	}
+
+	encoder.write([]byte{EOFHeader})
+	if encoder.err != nil {
+		return encoder.err
+	}
+
+	// the checksum covers every byte written before it, including the EOF marker
+	checksumBytes := make([]byte, 8)
+	binary.LittleEndian.PutUint64(checksumBytes, checksum.crc)
+	if _, err := w.Write(checksumBytes); err != nil {
+		return fmt.Errorf("error in writing rdb checksum: %s", err.Error())
+	}
+	return nil
+}
+
+// rdbEncoder writes the sections of an RDB dump, the first error encountered is kept and every later write is skipped.
+type rdbEncoder struct {
+	w io.Writer
+	err error
+}
+
+func (e *rdbEncoder) write(data []byte) {
+	if e.err != nil {
+		return
+	}
+	if _, err := e.w.Write(data); err != nil {
+		e.err = fmt.Errorf("error in writing rdb file: %s", err.Error())
+	}
+}
+
+// writeMetadata writes an auxiliary field of the metadata section.
+func (e *rdbEncoder) writeMetadata(key, value string) {
+	e.write([]byte{MetadataHeader})
+	e.writeString(key)
+	e.writeString(value)
+}
+
+// writeDatabase writes the database selector, the resize hints and every key of a database.
+func (e *rdbEncoder) writeDatabase(database RDBDatabase) {
+	expiryHashTableSize := 0
+	for _, val := range database.KVMap {
+		if val.ExpireAt != 0 {
+			expiryHashTableSize++
+		}
+	}
+
+	e.write([]byte{DatabaseHeader})
+	e.writeLength(uint64(database.DatabaseNumber))
+	e.write([]byte{ResizeDBHeader})
+	e.writeLength(uint64(len(database.KVMap)))
+	e.writeLength(uint64(expiryHashTableSize))
+
+	for key, val := range database.KVMap {
+		if val.ExpireAt != 0 {
+			expiry := make([]byte, 8)
+			binary.LittleEndian.PutUint64(expiry, val.ExpireAt)
+			e.write([]byte{KeyExpiryHeaderMS})
+			e.write(expiry)
+		}
+		e.writeValue(key, val)
+	}
+}
+
+// writeValue writes the value type, the key and the value of a key. Values are written in their plain encodings, lists,
+// sets and hashes as a length followed by their elements, sorted sets with binary scores and streams as a single
+// listpack of entries.
+func (e *rdbEncoder) writeValue(key string, val KVValue) {
+	switch val.Type {
+		case ListType: {
+			e.write([]byte{TypeList})
+			e.writeString(key)
+			e.writeLength(uint64(len(val.List)))
+			for _, elem := range val.List {
+				e.writeString(elem)
+			}
+		}
+		case SetType: {
+			e.write([]byte{TypeSet})
+			e.writeString(key)
+			e.writeLength(uint64(len(val.Set)))
+			for _, elem := range val.Set {
+				e.writeString(elem)
+			}
+		}
+		case ZSetType: {
+			e.write([]byte{TypeZSet2})
+			e.writeString(key)
+			e.writeLength(uint64(len(val.ZSet)))
+			score := make([]byte, 8)
+			for _, member := range val.ZSet {
+				e.writeString(member.Member)
+				binary.LittleEndian.PutUint64(score, math.Float64bits(member.Score))
+				e.write(score)
+			}
+		}
+		case HashType: {
+			e.write([]byte{TypeHash})
+			e.writeString(key)
+			e.writeLength(uint64(len(val.Hash)))
+			for field, value := range val.Hash {
+				e.writeString(field)
+				e.writeString(value)
+			}
+		}
+		case StreamType: {
+			e.write([]byte{TypeStreamListpacks3})
+			e.writeString(key)
+			e.writeStream(val.Stream)
+		}
+		default: {
+			e.write([]byte{TypeString})
+			e.writeString(key)
+			e.writeString(val.Value)
+		}
+	}
+}
+
+/*
+	writeStream writes a stream: its entries, its metadata and its consumer groups. The entries are packed in a single
+	listpack whose master entry holds the fields of the first entry. Pending entries and consumers are not kept by
+	the store, so every group is written without them.
+
+	Function Signature:
+		func (e *rdbEncoder) writeStream(stream *Stream)
+
+	Parameters:
+		- stream: The stream to write. (*Stream)
+*/
+func (e *rdbEncoder) writeStream(stream *Stream) {
+	if stream == nil {
+		stream = &Stream{}
+	}
+
+	if len(stream.Entries) == 0 {
+		e.writeLength(0)
+	} else {
+		masterID := stream.Entries[0].ID
+		masterFields := []string{}
+		for i := 0; i + 1 < len(stream.Entries[0].Fields); i += 2 {
+			masterFields = append(masterFields, stream.Entries[0].Fields[i])
+		}
+
+		elems := []string{
+			strconv.Itoa(len(stream.Entries)),
+			"0",
+			strconv.Itoa(len(masterFields)),
+		}
+		elems = append(elems, masterFields...)
+		elems = append(elems, "0")
+
+		for _, entry := range stream.Entries {
+			numFields := len(entry.Fields) / 2
+			sameFields := numFields == len(masterFields)
+			for i := 0; sameFields && i < numFields; i++ {
+				sameFields = entry.Fields[2 * i] == masterFields[i]
+			}
+
+			flags, lpCount := 0, 0
+			if sameFields {
+				flags = streamItemFlagSameFields
+				lpCount = numFields + 3
+			} else {
+				lpCount = 2 * numFields + 4
+			}
+			elems = append(elems,
+				strconv.Itoa(flags),
+				strconv.FormatInt(int64(entry.ID.Ms - masterID.Ms), 10),
+				strconv.FormatInt(int64(entry.ID.Seq - masterID.Seq), 10),
+			)
+			if sameFields {
+				for i := 0; i < numFields; i++ {
+					elems = append(elems, entry.Fields[2 * i + 1])
+				}
+			} else {
+				elems = append(elems, strconv.Itoa(numFields))
+				elems = append(elems, entry.Fields[:2 * numFields]...)
+			}
+			elems = append(elems, strconv.Itoa(lpCount))
+		}
+
+		masterKey := make([]byte, 16)
+		binary.BigEndian.PutUint64(masterKey[:8], masterID.Ms)
+		binary.BigEndian.PutUint64(masterKey[8:], masterID.Seq)
+
+		e.writeLength(1)
+		e.writeString(string(masterKey))
+		e.writeString(string(encodeListpack(elems)))
+	}
+
+	e.writeLength(stream.Length)
+	e.writeStreamID(stream.LastID)
+	e.writeStreamID(stream.FirstID)
+	e.writeStreamID(stream.MaxDeletedID)
+	e.writeLength(stream.EntriesAdded)
+
+	e.writeLength(uint64(len(stream.Groups)))
+	for _, group := range stream.Groups {
+		e.writeString(group.Name)
+		e.writeStreamID(group.LastID)
+		e.writeLength(uint64(group.EntriesRead))
+		e.writeLength(0) // pending entries
+		e.writeLength(0) // consumers
+	}
+}
+
+// writeStreamID writes the two parts of a stream ID as lengths.
+func (e *rdbEncoder) writeStreamID(id StreamID) {
+	e.writeLength(id.Ms)
+	e.writeLength(id.Seq)
+}
+
+/*
+	encodeListpack encodes elements into a listpack blob, elements holding a canonical integer use the smallest
+	integer encoding that fits and every other element is encoded as a string.
+
+	Function Signature:
+		func encodeListpack(elems []string) []byte
+
+	Parameters:
+		- elems: The elements to encode. ([]string)
+
+	Returns:
+		- []byte - The listpack.
+
+	Example Usage:
+		listpack := encodeListpack(["a", "1"])
+		// Output listpack = [12 0 0 0 2 0 129 97 2 1 1 255]
+*/
+func encodeListpack(elems []string) []byte {
+	listpack := make([]byte, 6)
+	for _, elem := range elems {
+		entry := encodeListpackEntry(elem)
+		listpack = append(listpack, entry...)
+		listpack = append(listpack, encodeListpackBacklen(len(entry))...)
+	}
+	listpack = append(listpack, 0xFF)
+
+	numElems := len(elems)
+	if numElems > 0xFFFF {
+		// the count saturates and readers have to walk the listpack instead
+		numElems = 0xFFFF
+	}
+	binary.LittleEndian.PutUint32(listpack[:4], uint32(len(listpack)))
+	binary.LittleEndian.PutUint16(listpack[4:6], uint16(numElems))
+	return listpack
+}
+
+// encodeListpackEntry encodes the encoding and data of a single listpack element.
+func encodeListpackEntry(elem string) []byte {
+	if num, err := strconv.ParseInt(elem, 10, 64); err == nil && strconv.FormatInt(num, 10) == elem {
+		switch {
+			case num >= 0 && num <= 127: {
+				return []byte{byte(num)}
+			}
+			case num >= -4096 && num <= 4095: {
+				return []byte{0xC0 | byte(num >> 8) & 0x1F, byte(num)}
+			}
+		}
+		size, encoding := 8, byte(0xF4)
+		switch {
+			case num >= math.MinInt16 && num <= math.MaxInt16: {
+				size, encoding = 2, 0xF1
+			}
+			case num >= -(1 << 23) && num < 1 << 23: {
+				size, encoding = 3, 0xF2
+			}
+			case num >= math.MinInt32 && num <= math.MaxInt32: {
+				size, encoding = 4, 0xF3
+			}
+		}
+		entry := []byte{encoding}
+		for i := 0; i < size; i++ {
+			entry = append(entry, byte(uint64(num) >> (8 * i)))
+		}
+		return entry
+	}
+
+	length := len(elem)
+	var entry []byte
+	switch {
+		case length < 1 << 6: {
+			entry = []byte{0x80 | byte(length)}
+		}
+		case length < 1 << 12: {
+			entry = []byte{0xE0 | byte(length >> 8), byte(length)}
+		}
+		default: {
+			entry = make([]byte, 5)
+			entry[0] = 0xF0
+			binary.LittleEndian.PutUint32(entry[1:], uint32(length))
+		}
+	}
+	return append(entry, elem...)
+}
+
+// encodeListpackBacklen encodes the backward length of a listpack element, 7 bits per byte with the most significant
+// bits first and the continuation bit set on every byte but the first.
+func encodeListpackBacklen(entryLength int) []byte {
+	size := listpackBacklenSize(entryLength)
+	backlen := make([]byte, size)
+	for i := 0; i < size; i++ {
+		backlen[i] = byte(entryLength >> (7 * (size - 1 - i))) & 0x7F
+		if i > 0 {
+			backlen[i] |= 0x80
+		}
+	}
+	return backlen
+}
+
+// writeString writes a string, strings holding a canonical 32 bit integer are stored as the integer and every other
+// string is prefixed by its length.
+func (e *rdbEncoder) writeString(str string) {
+	if num, err := strconv.ParseInt(str, 10, 32); err == nil && strconv.FormatInt(num, 10) == str {
+		switch {
+			case num >= math.MinInt8 && num <= math.MaxInt8: {
+				e.write([]byte{0xC0, byte(num)})
+			}
+			case num >= math.MinInt16 && num <= math.MaxInt16: {
+				encoded := []byte{0xC1, 0, 0}
+				binary.LittleEndian.PutUint16(encoded[1:], uint16(num))
+				e.write(encoded)
+			}
+			default: {
+				encoded := []byte{0xC2, 0, 0, 0, 0}
+				binary.LittleEndian.PutUint32(encoded[1:], uint32(num))
+				e.write(encoded)
+			}
+		}
+		return
+	}
+
+	e.writeLength(uint64(len(str)))
+	e.write([]byte(str))
+}
+
+/*
+	writeLength writes a length using the rdb length encoding specification, the smallest encoding that fits is used.
+
+	Function Signature:
+		func (e *rdbEncoder) writeLength(length uint64)
+
+	Parameters:
+		- length: The length to encode. (uint64)
+
+	Example Usage:
+		e.writeLength(10)
+		// Output [10]
+		e.writeLength(700)
+		// Output [66 188]
+*/
+func (e *rdbEncoder) writeLength(length uint64) {
+	switch {
+		case length < 1 << 6: {
+			e.write([]byte{byte(length)})
+		}
+		case length < 1 << 14: {
+			e.write([]byte{byte(length >> 8) | 0b01000000, byte(length)})
+		}
+		case length <= 0xFFFFFFFF: {
+			encoded := make([]byte, 5)
+			encoded[0] = 0x80
+			binary.BigEndian.PutUint32(encoded[1:], uint32(length))
+			e.write(encoded)
+		}
+		default: {
+			encoded := make([]byte, 9)
+			encoded[0] = 0x81
+			binary.BigEndian.PutUint64(encoded[1:], length)
+			e.write(encoded)
+		}
+	}
+}
+
+// rdbFilePath returns the path of the dump named fileName in dirPath.
+func rdbFilePath(dirPath, fileName string) string {
+	if dirPath == "" {
+		return fileName
+	}
+	return dirPath + "/" + fileName
+}
@@ -0,0 +1,278 @@
+package store
+
+import (
+	"fmt"
+	"io"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"memodb/internal/store/rdb"
+)
+
+// SaveParam is a save point: the dataset is saved once Changes changes were made and Seconds seconds went by since the
+// last save.
+type SaveParam struct {
+	Seconds int64
+	Changes int64
+}
+
+// bgsaveRetryDelay is how long save points wait before retrying a background save that failed.
+const bgsaveRetryDelay = 5
+
+var (
+	// saveMutex makes sure only one RDB dump is written at a time
+	saveMutex sync.Mutex
+	bgsaveInProgress int32
+	bgsaveScheduled int32
+	lastSave = time.Now().Unix()
+	lastBgsaveTry int64
+	lastBgsaveOk int32 = 1
+	lastBgsaveDuration int64 = -1
+
+	// saveParamsMutex guards saveParams
+	saveParamsMutex sync.Mutex
+	saveParams []SaveParam
+)
+
+// ParseSaveParams parses save points given as "<seconds> <changes> [<seconds> <changes> ...]", an empty string
+// disables automatic saves.
+func ParseSaveParams(str string) ([]SaveParam, error) {
+	fields := strings.Fields(str)
+	if len(fields) % 2 != 0 {
+		return nil, fmt.Errorf("invalid save parameters, expected pairs of <seconds> <changes>")
+	}
+
+	params := []SaveParam{}
+	for idx := 0; idx < len(fields); idx += 2 {
+		seconds, err := strconv.ParseInt(fields[idx], 10, 64)
+		if err != nil || seconds < 1 {
+			return nil, fmt.Errorf("invalid save parameters, seconds must be a positive integer")
+		}
+		changes, err := strconv.ParseInt(fields[idx + 1], 10, 64)
+		if err != nil || changes < 0 {
+			return nil, fmt.Errorf("invalid save parameters, changes must be a non negative integer")
+		}
+		params = append(params, SaveParam{Seconds: seconds, Changes: changes})
+	}
+	return params, nil
+}
+
+// FormatSaveParams formats save points the way they are parsed by ParseSaveParams.
+func FormatSaveParams(params []SaveParam) string {
+	fields := []string{}
+	for _, param := range params {
+		fields = append(fields, strconv.FormatInt(param.Seconds, 10), strconv.FormatInt(param.Changes, 10))
+	}
+	return strings.Join(fields, " ")
+}
+
+// SetSaveParams replaces the save points checked by the save scheduler.
+func SetSaveParams(params []SaveParam) {
+	saveParamsMutex.Lock()
+	defer saveParamsMutex.Unlock()
+	saveParams = params
+}
+
+// GetSaveParams returns the save points checked by the save scheduler.
+func GetSaveParams() []SaveParam {
+	saveParamsMutex.Lock()
+	defer saveParamsMutex.Unlock()
+	return append([]SaveParam{}, saveParams...)
+}
+
+// StartSaveScheduler starts checking the save points every second, a background save is started as soon as one of
+// them is reached. location returns the directory and the name of the RDB dump at the time of the save.
+func StartSaveScheduler(location func() (string, string)) {
+	go func() {
+		for range time.Tick(time.Second) {
+			if !saveParamReached() {
+				continue
+			}
+			dirPath, fileName := location()
+			fmt.Printf("%d changes since last save, saving...\n", Dirty())
+			if _, err := BackgroundSaveRdb(dirPath, fileName, false); err != nil {
+				fmt.Printf("Background saving error: %s\n", err.Error())
+			}
+		}
+	}()
+}
+
+// saveParamReached checks if one of the save points is reached. Once a background save failed, save points wait
+// a few seconds before trying again.
+func saveParamReached() bool {
+	if IsBackgroundSaveInProgress() {
+		return false
+	}
+	now := time.Now().Unix()
+	if atomic.LoadInt32(&lastBgsaveOk) == 0 && now - atomic.LoadInt64(&lastBgsaveTry) <= bgsaveRetryDelay {
+		return false
+	}
+
+	changes := Dirty()
+	for _, param := range GetSaveParams() {
+		if changes >= param.Changes && changes > 0 && now - LastSave() >= param.Seconds {
+			return true
+		}
+	}
+	return false
+}
+
+// Dirty returns the number of changes made to the store since the last successful save.
+func Dirty() int64 {
+	return atomic.LoadInt64(&dirty)
+}
+
+// ResetDirty forgets about the changes made so far, it is used once the dataset is loaded at startup.
+func ResetDirty() {
+	atomic.StoreInt64(&dirty, 0)
+}
+
+// Snapshot returns a point in time copy of the store, in the form written to RDB dumps. Expired keys are skipped.
+func Snapshot() []rdb.RDBDatabase {
+	snapshot, _ := takeSnapshot()
+	return snapshot
+}
+
+// WriteSnapshot writes an RDB dump of a snapshot returned by Snapshot to w, which is how a master transfers its dataset
+// to a replica.
+func WriteSnapshot(w io.Writer, snapshot []rdb.RDBDatabase) error {
+	var memStats runtime.MemStats
+	runtime.ReadMemStats(&memStats)
+	return rdb.EncodeRdb(w, &rdb.RDBType{Databases: snapshot}, memStats.Alloc)
+}
+
+// takeSnapshot returns a point in time copy of the store along with the number of changes it includes.
+func takeSnapshot() ([]rdb.RDBDatabase, int64) {
+	mutex.RLock()
+	defer mutex.RUnlock()
+
+	now := uint64(time.Now().UnixMilli())
+	snapshot := make([]rdb.RDBDatabase, 0, len(databases))
+	for db, keys := range databases {
+		kvMap := make(map[string]rdb.KVValue, len(keys))
+		for key, val := range keys {
+			if val.isExpired(now) {
+				continue
+			}
+			if val.object != nil {
+				// typed values are never modified in place, sharing them with the snapshot is safe
+				object := *val.object
+				object.ExpireAt = val.expireAt
+				kvMap[key] = object
+				continue
+			}
+			kvMap[key] = rdb.KVValue{
+				Value: val.value,
+				ExpireAt: val.expireAt,
+			}
+		}
+		snapshot = append(snapshot, rdb.RDBDatabase{
+			DatabaseNumber: db,
+			KVMap: kvMap,
+		})
+	}
+
+	return snapshot, Dirty()
+}
+
+// SaveRdb synchronously writes the current contents of the store to the RDB dump at dirPath/fileName.
+func SaveRdb(dirPath, fileName string) error {
+	if IsBackgroundSaveInProgress() {
+		return fmt.Errorf("ERR Background save already in progress")
+	}
+	snapshot, changes := takeSnapshot()
+	return saveSnapshot(dirPath, fileName, snapshot, changes)
+}
+
+// SaveRdbOnShutdown synchronously writes the current contents of the store to the RDB dump at dirPath/fileName, as
+// done before shutting down. Unlike SaveRdb it waits for the background save in progress, whose snapshot may be older,
+// instead of failing.
+func SaveRdbOnShutdown(dirPath, fileName string) error {
+	for IsBackgroundSaveInProgress() {
+		time.Sleep(10 * time.Millisecond)
+	}
+	snapshot, changes := takeSnapshot()
+	return saveSnapshot(dirPath, fileName, snapshot, changes)
+}
+
+// BackgroundSaveRdb writes the current contents of the store to the RDB dump at dirPath/fileName without blocking the
+// caller. The snapshot is taken before returning, so writes made afterwards are not part of the dump.
+// When a background save is already in progress the save is refused, unless schedule is set in which case it runs as
+// soon as the current one completes. It returns whether the save was scheduled instead of started.
+func BackgroundSaveRdb(dirPath, fileName string, schedule bool) (bool, error) {
+	if !atomic.CompareAndSwapInt32(&bgsaveInProgress, 0, 1) {
+		if !schedule {
+			return false, fmt.Errorf("ERR Background save already in progress")
+		}
+		atomic.StoreInt32(&bgsaveScheduled, 1)
+		return true, nil
+	}
+
+	snapshot, changes := takeSnapshot()
+	go func() {
+		for {
+			start := time.Now()
+			atomic.StoreInt64(&lastBgsaveTry, start.Unix())
+			err := saveSnapshot(dirPath, fileName, snapshot, changes)
+			atomic.StoreInt64(&lastBgsaveDuration, int64(time.Since(start).Seconds()))
+			if err != nil {
+				atomic.StoreInt32(&lastBgsaveOk, 0)
+				fmt.Printf("Background saving error: %s\n", err.Error())
+			} else {
+				atomic.StoreInt32(&lastBgsaveOk, 1)
+				fmt.Println("Background saving terminated with success")
+			}
+
+			atomic.StoreInt32(&bgsaveInProgress, 0)
+			// a save scheduled while this one was running takes over, unless another save started in between
+			if atomic.LoadInt32(&bgsaveScheduled) == 0 || !atomic.CompareAndSwapInt32(&bgsaveInProgress, 0, 1) {
+				return
+			}
+			atomic.StoreInt32(&bgsaveScheduled, 0)
+			snapshot, changes = takeSnapshot()
+		}
+	}()
+	return false, nil
+}
+
+// IsBackgroundSaveInProgress checks if an RDB dump is being written in the background.
+func IsBackgroundSaveInProgress() bool {
+	return atomic.LoadInt32(&bgsaveInProgress) == 1
+}
+
+// LastSave returns the unix time of the last successful RDB dump, or of the server start if there was none.
+func LastSave() int64 {
+	return atomic.LoadInt64(&lastSave)
+}
+
+// LastBackgroundSaveStatus returns "ok" if the last background save succeeded (or if there was none) and "err"
+// otherwise, along with how long it took in seconds (-1 if there was none).
+func LastBackgroundSaveStatus() (string, int64) {
+	status := "ok"
+	if atomic.LoadInt32(&lastBgsaveOk) == 0 {
+		status = "err"
+	}
+	return status, atomic.LoadInt64(&lastBgsaveDuration)
+}
+
+// saveSnapshot writes a snapshot to the RDB dump at dirPath/fileName and records the time of the save.
+// The changes included in the snapshot are no longer counted as dirty once it is saved.
+func saveSnapshot(dirPath, fileName string, snapshot []rdb.RDBDatabase, changes int64) error {
+	saveMutex.Lock()
+	defer saveMutex.Unlock()
+
+	var memStats runtime.MemStats
+	runtime.ReadMemStats(&memStats)
+
+	err := rdb.WriteRdbFile(dirPath, fileName, &rdb.RDBType{Databases: snapshot}, memStats.Alloc)
+	if err != nil {
+		return err
+	}
+	atomic.AddInt64(&dirty, -changes)
+	atomic.StoreInt64(&lastSave, time.Now().Unix())
+	return nil
+}
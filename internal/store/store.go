@@ -1,48 +1,98 @@
 package store
 
 import (
+	"fmt"
+	"io"
 	"memodb/internal/store/rdb"
+	"os"
+	"path/filepath"
 	"sync"
+	"sync/atomic"
 	"time"
 )
 
 type data struct {
 	value string;
+	// object holds the value of keys that are not strings, it is nil for strings
+	object *rdb.KVValue;
 	createdAt uint;
 	expireAt uint64;
 }
-var store = make(map[string]data)
-var mutex sync.Mutex;
+// databases holds the keys of every logical database, indexed by database number
+var databases = newDatabases(16)
+// mutex guards databases, every connection is served by its own goroutine so reads take the read lock and writes,
+// including the removal of expired keys, take the write lock
+var mutex sync.RWMutex;
+// dirty counts the changes made to the store since the last successful save, it is updated atomically
+var dirty int64
 
-func SetStore(key, val string, args ...uint) {
+func newDatabases(count int) []map[string]data {
+	dbs := make([]map[string]data, count)
+	for idx := range dbs {
+		dbs[idx] = make(map[string]data)
+	}
+	return dbs
+}
+
+// SetDatabaseCount sets the number of logical databases, every key stored so far is dropped.
+func SetDatabaseCount(count int) {
+	mutex.Lock()
+	defer mutex.Unlock()
+	databases = newDatabases(count)
+}
+
+// DatabaseCount returns the number of logical databases.
+func DatabaseCount() int {
+	mutex.RLock()
+	defer mutex.RUnlock()
+	return len(databases)
+}
+
+func SetStore(db int, key, val string, args ...uint) {
 	mutex.Lock()
 	defer mutex.Unlock()
 	timestamp := uint(time.Now().UnixMilli())
+	atomic.AddInt64(&dirty, 1)
 
 	if len(args) > 0 {
-		store[key] = data {
+		databases[db][key] = data {
 			value: val,
 			createdAt: timestamp,
 			expireAt: uint64(timestamp + args[0]),
 		}
 	} else {
-		store[key] = data {
+		databases[db][key] = data {
 			value: val,
 			createdAt: timestamp,
 		}
 	}
 }
 
-func GetStore(key string) (string, bool) {
+// SetStoreExpireAt stores a value that expires at the given unix time in milliseconds.
+func SetStoreExpireAt(db int, key, val string, expireAt uint64) {
+	mutex.Lock()
+	defer mutex.Unlock()
+	atomic.AddInt64(&dirty, 1)
+
+	databases[db][key] = data {
+		value: val,
+		createdAt: uint(time.Now().UnixMilli()),
+		expireAt: expireAt,
+	}
+}
+
+func GetStore(db int, key string) (string, bool) {
     // Check if the key is present in the store
-    val, isPresent := store[key]
+    mutex.RLock()
+    val, isPresent := databases[db][key]
+    mutex.RUnlock()
     if !isPresent {
         return "", false // Key doesn't exist
     }
     
     // If there is an expiration set and it's expired, remove the key
-    if val.expireAt != 0 && uint64(time.Now().UnixMilli()) >= val.expireAt {
-        delete(store, key)
+    if val.isExpired(uint64(time.Now().UnixMilli())) {
+        deleteExpired(db, key)
         return "", false
     }
 
@@ -50,33 +100,175 @@ func GetStore(key string) (string, bool) {
     return val.value, true
 }
 
-func GetKeys() []string {
+// GetType returns the type of the value held by a key, the key must exist and must not have expired.
+func GetType(db int, key string) (rdb.ValueType, bool) {
+	mutex.RLock()
+	val, isPresent := databases[db][key]
+	mutex.RUnlock()
+	if !isPresent || val.isExpired(uint64(time.Now().UnixMilli())) {
+		return rdb.StringType, false
+	}
+	if val.object != nil {
+		return val.object.Type, true
+	}
+	return rdb.StringType, true
+}
+
+func GetKeys(db int) []string {
 	keys := []string{}
-	for key, val := range store {
-		if val.expireAt > 0 && uint64(time.Now().UnixMilli()) >= val.expireAt {
-			delete(store, key)
+	expired := []string{}
+	now := uint64(time.Now().UnixMilli())
+	mutex.RLock()
+	for key, val := range databases[db] {
+		if val.isExpired(now) {
+			expired = append(expired, key)
 		} else {
 			keys = append(keys, key)
 		}
 	}
+	mutex.RUnlock()
+
+	for _, key := range expired {
+		deleteExpired(db, key)
+	}
 	return keys
 }
 
-func LoadRdbInStore(dirPath, fileName string) (bool, error) {
-	parsedRdb, err := rdb.ParseRdbFile(dirPath, fileName)
+// isExpired checks if a value has an expiry that is reached at the unix time now, in milliseconds.
+func (val data) isExpired(now uint64) bool {
+	return val.expireAt != 0 && now >= val.expireAt
+}
 
-	if err != nil {
-		return false, err
+// deleteExpired removes a key found expired while holding the read lock. The key is checked again under the write lock
+// since it may have been set to a new value in between.
+func deleteExpired(db int, key string) {
+	mutex.Lock()
+	defer mutex.Unlock()
+	if val, isPresent := databases[db][key]; isPresent && val.isExpired(uint64(time.Now().UnixMilli())) {
+		delete(databases[db], key)
+		atomic.AddInt64(&dirty, 1)
 	}
+}
 
-	for _, database := range parsedRdb.Databases {
-		for key, val := range database.KVMap {
-			store[key] = data {
-				value: val.Value,
-				expireAt: val.ExpireAt,
-			}
+// DbSize returns the number of keys of a database, keys that expired but were not removed yet are counted.
+func DbSize(db int) int {
+	size, _ := DbStats(db)
+	return size
+}
+
+// DbStats returns the number of keys of a database along with how many of them have an expiry.
+func DbStats(db int) (int, int) {
+	mutex.RLock()
+	defer mutex.RUnlock()
+
+	size, expires := 0, 0
+	for _, val := range databases[db] {
+		size++
+		if val.expireAt != 0 {
+			expires++
 		}
 	}
+	return size, expires
+}
+
+// FlushDb removes every key of a database. The memory of the removed keys is reclaimed by the garbage collector in
+// the background, so the flush never blocks on it.
+func FlushDb(db int) {
+	mutex.Lock()
+	defer mutex.Unlock()
+	flushDatabase(db)
+}
+
+// FlushAll removes every key of every database.
+func FlushAll() {
+	mutex.Lock()
+	defer mutex.Unlock()
+	for db := range databases {
+		flushDatabase(db)
+	}
+}
+
+// flushDatabase replaces a database with an empty one. The caller holds the mutex.
+func flushDatabase(db int) {
+	atomic.AddInt64(&dirty, int64(len(databases[db])))
+	databases[db] = make(map[string]data)
+}
+
+// SwapDb swaps the keys of two databases, clients connected to either database see the keys of the other one at once.
+func SwapDb(db1, db2 int) {
+	mutex.Lock()
+	defer mutex.Unlock()
+	if db1 == db2 {
+		return
+	}
+
+	databases[db1], databases[db2] = databases[db2], databases[db1]
+	atomic.AddInt64(&dirty, 1)
+}
+
+// MoveKey moves a key from the database src to the database dst. The key is only moved when it exists in src and does
+// not exist in dst, it returns whether it was moved.
+func MoveKey(src, dst int, key string) bool {
+	mutex.Lock()
+	defer mutex.Unlock()
+
+	now := uint64(time.Now().UnixMilli())
+	val, isPresent := databases[src][key]
+	if !isPresent || val.isExpired(now) {
+		return false
+	}
+	if target, isPresent := databases[dst][key]; isPresent && !target.isExpired(now) {
+		return false
+	}
+
+	databases[dst][key] = val
+	delete(databases[src], key)
+	atomic.AddInt64(&dirty, 1)
+	return true
+}
+
+// LoadRdbInStore loads the keys of the RDB dump at dirPath/fileName in the store, one at a time as they are decoded
+// and each one under the write lock. It returns whether a dump was loaded, a missing dump is not an error.
+func LoadRdbInStore(dirPath, fileName string) (bool, error) {
+	if _, err := os.Stat(filepath.Join(dirPath, fileName)); os.IsNotExist(err) {
+		return false, nil
+	}
+	_, err := rdb.DecodeRdbFile(dirPath, fileName, LoadKey)
+	if err != nil {
+		return false, err
+	}
 
 	return true, nil
-}
\ No newline at end of file
+}
+
+// LoadRdb replaces the contents of the store with the RDB dump read from r, which is how a replica loads the snapshot
+// sent by its master during a full resynchronization.
+func LoadRdb(r io.Reader) error {
+	mutex.Lock()
+	databases = newDatabases(len(databases))
+	mutex.Unlock()
+
+	_, err := rdb.DecodeRdb(r, LoadKey)
+	return err
+}
+
+// LoadKey adds a key decoded from a dump to the store, loading does not count as a change.
+func LoadKey(databaseNumber int, key string, val rdb.KVValue) error {
+	mutex.Lock()
+	defer mutex.Unlock()
+	if databaseNumber < 0 || databaseNumber >= len(databases) {
+		return fmt.Errorf("the dump was created with more than %d databases, set databases to a larger value", len(databases))
+	}
+
+	entry := data {
+		value: val.Value,
+		createdAt: uint(time.Now().UnixMilli()),
+		expireAt: val.ExpireAt,
+	}
+	if val.Type != rdb.StringType {
+		object := val
+		entry.object = &object
+	}
+	databases[databaseNumber][key] = entry
+	return nil
+}
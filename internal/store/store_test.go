@@ -0,0 +1,117 @@
+package store
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+)
+
+// TestConcurrentAccess hammers the store from many goroutines at once, like connections served concurrently do. It is
+// meant to be run with go test -race, which reports any access to the databases made without the lock.
+func TestConcurrentAccess(t *testing.T) {
+	SetDatabaseCount(4)
+	const goroutines = 32
+	const iterations = 2000
+
+	var wg sync.WaitGroup
+	for g := 0; g < goroutines; g++ {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+			for i := 0; i < iterations; i++ {
+				key := fmt.Sprintf("key:%d", (g + i) % 64)
+				db := i % 2
+				switch i % 10 {
+					case 0: {
+						// expires almost at once, so reads race with the lazy removal of expired keys
+						SetStore(db, key, "expiring", 1)
+					}
+					case 1: {
+						SetStore(db, key, "value")
+					}
+					case 2: {
+						SetStoreExpireAt(db, key, "expired", uint64(time.Now().UnixMilli()) - 1)
+					}
+					case 3: {
+						GetStore(db, key)
+						GetType(db, key)
+					}
+					case 4: {
+						GetKeys(db)
+					}
+					case 5: {
+						MoveKey(db, 1 - db, key)
+					}
+					case 6: {
+						if i % 100 == 6 {
+							SwapDb(2, 3)
+						}
+						DbStats(db)
+					}
+					case 7: {
+						if i % 200 == 7 {
+							FlushDb(2 + g % 2)
+						}
+						SetStore(2 + g % 2, key, "other")
+					}
+					case 8: {
+						if i % 100 == 8 {
+							Snapshot()
+						}
+					}
+					case 9: {
+						GetStore(db, key)
+					}
+				}
+			}
+		}(g)
+	}
+	wg.Wait()
+
+	// whatever the interleaving, no expired key is ever returned
+	now := uint64(time.Now().UnixMilli())
+	for _, database := range Snapshot() {
+		for key, val := range database.KVMap {
+			if val.ExpireAt != 0 && val.ExpireAt <= now {
+				t.Errorf("snapshot of db %d holds expired key %s", database.DatabaseNumber, key)
+			}
+		}
+	}
+}
+
+// TestLazyExpiry checks that an expired key is reported missing and removed by GetStore and GetKeys.
+func TestLazyExpiry(t *testing.T) {
+	SetDatabaseCount(1)
+	SetStoreExpireAt(0, "gone", "value", uint64(time.Now().UnixMilli()) - 1)
+	SetStore(0, "kept", "value")
+
+	if _, isPresent := GetStore(0, "gone"); isPresent {
+		t.Fatalf("GetStore returned an expired key")
+	}
+	if size := DbSize(0); size != 1 {
+		t.Fatalf("expected the expired key to be removed, DbSize = %d", size)
+	}
+
+	SetStoreExpireAt(0, "gone", "value", uint64(time.Now().UnixMilli()) - 1)
+	keys := GetKeys(0)
+	if len(keys) != 1 || keys[0] != "kept" {
+		t.Fatalf("expected GetKeys to return [kept], got %v", keys)
+	}
+	if size := DbSize(0); size != 1 {
+		t.Fatalf("expected the expired key to be removed, DbSize = %d", size)
+	}
+}
+
+// TestExpiredKeySetAgain checks that a key set again after it was found expired is not removed with the old value.
+func TestExpiredKeySetAgain(t *testing.T) {
+	SetDatabaseCount(1)
+	SetStoreExpireAt(0, "key", "old", uint64(time.Now().UnixMilli()) - 1)
+	SetStore(0, "key", "new")
+	// the removal of the old value, had it been found expired before the key was set again, must check it again
+	deleteExpired(0, "key")
+
+	if val, isPresent := GetStore(0, "key"); !isPresent || val != "new" {
+		t.Fatalf("expected key to hold new, got %q (present %t)", val, isPresent)
+	}
+}
@@ -1,9 +1,14 @@
 package tcp
 
-import "net"
+import (
+	"net"
+
+	"memodb/internal/resp"
+)
 
 type TCPConnection struct { 
 	Connection net.Conn
+	Reader *resp.Reader
 	Initialized bool
 }
-var Connections []TCPConnection;
\ No newline at end of file
+var Connections []TCPConnection;
@@ -0,0 +1,91 @@
+package tcp
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"os"
+
+	"memodb/internal/config"
+)
+
+// ServerTLSConfig builds the TLS configuration of the listeners on tls-port out of tls-cert-file and tls-key-file.
+// Client certificates are checked against tls-ca-cert-file, tls-auth-clients tells whether they are required (yes),
+// checked when one is given (optional) or not asked for (no).
+func ServerTLSConfig() (*tls.Config, error) {
+	certificate, err := loadCertificate()
+	if err != nil {
+		return nil, err
+	}
+	tlsConfig := &tls.Config{
+		Certificates: []tls.Certificate{certificate},
+		MinVersion: tls.VersionTLS12,
+	}
+
+	authClients := config.Get("tls-auth-clients")
+	if authClients == "no" {
+		return tlsConfig, nil
+	}
+	if config.Get("tls-ca-cert-file") == "" {
+		return nil, fmt.Errorf("tls-ca-cert-file is required to authenticate clients, or set tls-auth-clients to no")
+	}
+	tlsConfig.ClientCAs, err = loadCertPool()
+	if err != nil {
+		return nil, err
+	}
+	tlsConfig.ClientAuth = tls.RequireAndVerifyClientCert
+	if authClients == "optional" {
+		tlsConfig.ClientAuth = tls.VerifyClientCertIfGiven
+	}
+	return tlsConfig, nil
+}
+
+// ClientTLSConfig builds the TLS configuration a replica connects to its master at serverName with. The certificate of
+// the master is checked against tls-ca-cert-file, or the system roots when it is not set, and the replica presents its
+// own tls-cert-file in case the master authenticates its clients.
+func ClientTLSConfig(serverName string) (*tls.Config, error) {
+	certificate, err := loadCertificate()
+	if err != nil {
+		return nil, err
+	}
+	tlsConfig := &tls.Config{
+		Certificates: []tls.Certificate{certificate},
+		ServerName: serverName,
+		MinVersion: tls.VersionTLS12,
+	}
+
+	if config.Get("tls-ca-cert-file") != "" {
+		tlsConfig.RootCAs, err = loadCertPool()
+		if err != nil {
+			return nil, err
+		}
+	}
+	return tlsConfig, nil
+}
+
+// loadCertificate loads the certificate of the server and its private key.
+func loadCertificate() (tls.Certificate, error) {
+	certFile, keyFile := config.Get("tls-cert-file"), config.Get("tls-key-file")
+	if certFile == "" || keyFile == "" {
+		return tls.Certificate{}, fmt.Errorf("tls-cert-file and tls-key-file are required to use TLS")
+	}
+	certificate, err := tls.LoadX509KeyPair(certFile, keyFile)
+	if err != nil {
+		return tls.Certificate{}, fmt.Errorf("error in loading the TLS certificate: %s", err.Error())
+	}
+	return certificate, nil
+}
+
+// loadCertPool loads the certificates of the authorities trusted to sign the certificates of peers.
+func loadCertPool() (*x509.CertPool, error) {
+	caFile := config.Get("tls-ca-cert-file")
+	pem, err := os.ReadFile(caFile)
+	if err != nil {
+		return nil, fmt.Errorf("error in reading tls-ca-cert-file: %s", err.Error())
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(pem) {
+		return nil, fmt.Errorf("no certificate found in tls-ca-cert-file %s", caFile)
+	}
+	return pool, nil
+}
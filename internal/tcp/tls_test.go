@@ -0,0 +1,313 @@
+package tcp
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"memodb/internal/config"
+)
+
+// the TLS parameters are registered by the commands package, the tests register their own copy
+func init() {
+	config.Register(
+		&config.Param{Name: "tls-cert-file", Type: config.StringParam, Default: "", Flags: config.FlagImmutable},
+		&config.Param{Name: "tls-key-file", Type: config.StringParam, Default: "", Flags: config.FlagImmutable},
+		&config.Param{Name: "tls-ca-cert-file", Type: config.StringParam, Default: "", Flags: config.FlagImmutable},
+		&config.Param{
+			Name: "tls-auth-clients", Type: config.EnumParam, Default: "yes", Flags: config.FlagImmutable,
+			Values: []string{"yes", "no", "optional"},
+		},
+	)
+}
+
+// testPKI holds the files of a certificate authority and of certificates generated for a test.
+type testPKI struct {
+	dir string
+	// caFile is the certificate of the trusted authority, certFile and keyFile a certificate it signed for localhost
+	caFile string
+	certFile string
+	keyFile string
+	// rogueCertFile and rogueKeyFile are a certificate for localhost signed by an authority nobody trusts
+	rogueCertFile string
+	rogueKeyFile string
+}
+
+// newTestPKI generates a certificate authority and the certificates signed by it in a temporary directory.
+func newTestPKI(t *testing.T) *testPKI {
+	pki := &testPKI{dir: t.TempDir()}
+	caCert, caKey := generateCertificate(t, "memodb test CA", nil, nil)
+	pki.caFile = writePEM(t, pki.dir, "ca.crt", "CERTIFICATE", caCert.Raw)
+
+	cert, key := generateCertificate(t, "localhost", caCert, caKey)
+	pki.certFile = writePEM(t, pki.dir, "node.crt", "CERTIFICATE", cert.Raw)
+	pki.keyFile = writeKey(t, pki.dir, "node.key", key)
+
+	rogueCaCert, rogueCaKey := generateCertificate(t, "rogue CA", nil, nil)
+	rogueCert, rogueKey := generateCertificate(t, "localhost", rogueCaCert, rogueCaKey)
+	pki.rogueCertFile = writePEM(t, pki.dir, "rogue.crt", "CERTIFICATE", rogueCert.Raw)
+	pki.rogueKeyFile = writeKey(t, pki.dir, "rogue.key", rogueKey)
+	return pki
+}
+
+// generateCertificate generates a certificate for commonName signed by parent, or a self-signed authority when parent
+// is nil. The certificates are valid for localhost both as servers and as clients, like a replica presenting the
+// certificate of its server to its master.
+func generateCertificate(t *testing.T, commonName string, parent *x509.Certificate, parentKey *ecdsa.PrivateKey) (*x509.Certificate, *ecdsa.PrivateKey) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("error in generating a key: %s", err.Error())
+	}
+	serial, err := rand.Int(rand.Reader, big.NewInt(1 << 62))
+	if err != nil {
+		t.Fatalf("error in generating a serial number: %s", err.Error())
+	}
+	template := &x509.Certificate{
+		SerialNumber: serial,
+		Subject: pkix.Name{CommonName: commonName},
+		NotBefore: time.Now().Add(-time.Hour),
+		NotAfter: time.Now().Add(time.Hour),
+	}
+	if parent == nil {
+		template.IsCA = true
+		template.BasicConstraintsValid = true
+		template.KeyUsage = x509.KeyUsageCertSign | x509.KeyUsageDigitalSignature
+		parent, parentKey = template, key
+	} else {
+		template.KeyUsage = x509.KeyUsageDigitalSignature
+		template.ExtKeyUsage = []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth}
+		template.DNSNames = []string{"localhost"}
+		template.IPAddresses = []net.IP{net.ParseIP("127.0.0.1")}
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, parent, &key.PublicKey, parentKey)
+	if err != nil {
+		t.Fatalf("error in creating the certificate of %s: %s", commonName, err.Error())
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatalf("error in parsing the certificate of %s: %s", commonName, err.Error())
+	}
+	return cert, key
+}
+
+// writePEM writes a PEM block to a file of dir and returns its path.
+func writePEM(t *testing.T, dir, name, blockType string, der []byte) string {
+	path := filepath.Join(dir, name)
+	if err := os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: blockType, Bytes: der}), 0600); err != nil {
+		t.Fatalf("error in writing %s: %s", name, err.Error())
+	}
+	return path
+}
+
+// writeKey writes a private key in PEM format to a file of dir and returns its path.
+func writeKey(t *testing.T, dir, name string, key *ecdsa.PrivateKey) string {
+	der, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatalf("error in encoding %s: %s", name, err.Error())
+	}
+	return writePEM(t, dir, name, "EC PRIVATE KEY", der)
+}
+
+// loadTLSParams sets the TLS parameters as read from the config file, the pairs are names followed by values.
+func loadTLSParams(t *testing.T, pairs ...string) {
+	for _, name := range []string{"tls-cert-file", "tls-key-file", "tls-ca-cert-file"} {
+		if err := config.Load(name, ""); err != nil {
+			t.Fatalf("error in resetting %s: %s", name, err.Error())
+		}
+	}
+	if err := config.Load("tls-auth-clients", "yes"); err != nil {
+		t.Fatalf("error in resetting tls-auth-clients: %s", err.Error())
+	}
+	for i := 0; i + 1 < len(pairs); i += 2 {
+		if err := config.Load(pairs[i], pairs[i + 1]); err != nil {
+			t.Fatalf("error in loading %s: %s", pairs[i], err.Error())
+		}
+	}
+}
+
+// handshake runs a TLS handshake between a listener using serverConfig and a client using clientConfig, it returns
+// the errors of both sides.
+func handshake(t *testing.T, serverConfig, clientConfig *tls.Config) (error, error) {
+	listener, err := tls.Listen("tcp", "127.0.0.1:0", serverConfig)
+	if err != nil {
+		t.Fatalf("error in listening: %s", err.Error())
+	}
+	defer listener.Close()
+
+	serverErr := make(chan error, 1)
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			serverErr <- err
+			return
+		}
+		defer conn.Close()
+		conn.SetDeadline(time.Now().Add(5 * time.Second))
+		serverErr <- conn.(*tls.Conn).Handshake()
+	}()
+
+	conn, clientErr := net.DialTimeout("tcp", listener.Addr().String(), 5 * time.Second)
+	if clientErr != nil {
+		t.Fatalf("error in connecting: %s", clientErr.Error())
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(5 * time.Second))
+	clientErr = tls.Client(conn, clientConfig).Handshake()
+	return <-serverErr, clientErr
+}
+
+// anonymousClient returns the configuration of a client trusting the test authority without presenting a certificate.
+func anonymousClient(t *testing.T, pki *testPKI) *tls.Config {
+	caPEM, err := os.ReadFile(pki.caFile)
+	if err != nil {
+		t.Fatalf("error in reading the CA: %s", err.Error())
+	}
+	pool := x509.NewCertPool()
+	pool.AppendCertsFromPEM(caPEM)
+	return &tls.Config{RootCAs: pool, ServerName: "localhost"}
+}
+
+// presenting returns a copy of clientConfig presenting the certificate of certFile and keyFile. It is presented even
+// when the server names other authorities, which a client left to choose would not do.
+func presenting(t *testing.T, clientConfig *tls.Config, certFile, keyFile string) *tls.Config {
+	certificate, err := tls.LoadX509KeyPair(certFile, keyFile)
+	if err != nil {
+		t.Fatalf("error in loading the client certificate: %s", err.Error())
+	}
+	clientConfig = clientConfig.Clone()
+	clientConfig.GetClientCertificate = func(*tls.CertificateRequestInfo) (*tls.Certificate, error) {
+		return &certificate, nil
+	}
+	return clientConfig
+}
+
+// TestServerHandshake checks that clients complete the handshake with the listeners on tls-port, and that their
+// certificates are asked for and checked as tls-auth-clients tells.
+func TestServerHandshake(t *testing.T) {
+	pki := newTestPKI(t)
+	tests := []struct {
+		name string
+		authClients string
+		// clientCert and clientKey are the certificate presented by the client, none when empty
+		clientCert string
+		clientKey string
+		success bool
+	}{
+		{"no, without certificate", "no", "", "", true},
+		{"yes, trusted certificate", "yes", pki.certFile, pki.keyFile, true},
+		{"yes, without certificate", "yes", "", "", false},
+		{"yes, untrusted certificate", "yes", pki.rogueCertFile, pki.rogueKeyFile, false},
+		{"optional, without certificate", "optional", "", "", true},
+		{"optional, trusted certificate", "optional", pki.certFile, pki.keyFile, true},
+		{"optional, untrusted certificate", "optional", pki.rogueCertFile, pki.rogueKeyFile, false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			loadTLSParams(t, "tls-cert-file", pki.certFile, "tls-key-file", pki.keyFile, "tls-ca-cert-file", pki.caFile,
+				"tls-auth-clients", test.authClients)
+			serverConfig, err := ServerTLSConfig()
+			if err != nil {
+				t.Fatalf("error in building the server configuration: %s", err.Error())
+			}
+			clientConfig := anonymousClient(t, pki)
+			if test.clientCert != "" {
+				clientConfig = presenting(t, clientConfig, test.clientCert, test.clientKey)
+			}
+
+			// the client may learn that its certificate was rejected only once it reads, the server always knows
+			serverErr, clientErr := handshake(t, serverConfig, clientConfig)
+			if test.success && (serverErr != nil || clientErr != nil) {
+				t.Fatalf("expected the handshake to succeed, server error: %v, client error: %v", serverErr, clientErr)
+			}
+			if !test.success && serverErr == nil {
+				t.Fatalf("expected the server to reject the client")
+			}
+		})
+	}
+}
+
+// TestServerTLSConfigErrors checks that the listeners are not started with an incomplete configuration.
+func TestServerTLSConfigErrors(t *testing.T) {
+	pki := newTestPKI(t)
+
+	loadTLSParams(t, "tls-cert-file", pki.certFile)
+	if _, err := ServerTLSConfig(); err == nil {
+		t.Fatalf("expected an error without tls-key-file")
+	}
+	loadTLSParams(t, "tls-cert-file", pki.certFile, "tls-key-file", pki.keyFile)
+	if _, err := ServerTLSConfig(); err == nil {
+		t.Fatalf("expected an error without tls-ca-cert-file while clients are authenticated")
+	}
+	loadTLSParams(t, "tls-cert-file", pki.certFile, "tls-key-file", pki.rogueKeyFile, "tls-auth-clients", "no")
+	if _, err := ServerTLSConfig(); err == nil {
+		t.Fatalf("expected an error with a key not matching the certificate")
+	}
+	loadTLSParams(t, "tls-cert-file", pki.certFile, "tls-key-file", pki.keyFile, "tls-ca-cert-file", pki.keyFile)
+	if _, err := ServerTLSConfig(); err == nil {
+		t.Fatalf("expected an error with a tls-ca-cert-file holding no certificate")
+	}
+}
+
+// TestReplicationHandshake checks the handshake of a replica using tls-replication with its master: the replica checks
+// the certificate of the master against tls-ca-cert-file and presents its own to a master authenticating its clients.
+func TestReplicationHandshake(t *testing.T) {
+	pki := newTestPKI(t)
+	loadTLSParams(t, "tls-cert-file", pki.certFile, "tls-key-file", pki.keyFile, "tls-ca-cert-file", pki.caFile)
+	masterConfig, err := ServerTLSConfig()
+	if err != nil {
+		t.Fatalf("error in building the master configuration: %s", err.Error())
+	}
+
+	replicaConfig, err := ClientTLSConfig("localhost")
+	if err != nil {
+		t.Fatalf("error in building the replica configuration: %s", err.Error())
+	}
+	if serverErr, clientErr := handshake(t, masterConfig, replicaConfig); serverErr != nil || clientErr != nil {
+		t.Fatalf("expected the replica to connect, master error: %v, replica error: %v", serverErr, clientErr)
+	}
+
+	// a master host not named in the certificate of the master
+	replicaConfig, err = ClientTLSConfig("master.example.com")
+	if err != nil {
+		t.Fatalf("error in building the replica configuration: %s", err.Error())
+	}
+	if _, clientErr := handshake(t, masterConfig, replicaConfig); clientErr == nil {
+		t.Fatalf("expected the replica to reject a certificate not issued to its master host")
+	}
+
+	// a master whose certificate is not signed by the authority the replica trusts
+	loadTLSParams(t, "tls-cert-file", pki.rogueCertFile, "tls-key-file", pki.rogueKeyFile, "tls-auth-clients", "no")
+	rogueConfig, err := ServerTLSConfig()
+	if err != nil {
+		t.Fatalf("error in building the rogue master configuration: %s", err.Error())
+	}
+	loadTLSParams(t, "tls-cert-file", pki.certFile, "tls-key-file", pki.keyFile, "tls-ca-cert-file", pki.caFile)
+	replicaConfig, err = ClientTLSConfig("localhost")
+	if err != nil {
+		t.Fatalf("error in building the replica configuration: %s", err.Error())
+	}
+	if _, clientErr := handshake(t, rogueConfig, replicaConfig); clientErr == nil {
+		t.Fatalf("expected the replica to reject a master certificate signed by an untrusted authority")
+	}
+
+	// a replica whose certificate is not signed by the authority the master trusts
+	loadTLSParams(t, "tls-cert-file", pki.rogueCertFile, "tls-key-file", pki.rogueKeyFile, "tls-ca-cert-file", pki.caFile)
+	replicaConfig, err = ClientTLSConfig("localhost")
+	if err != nil {
+		t.Fatalf("error in building the replica configuration: %s", err.Error())
+	}
+	if serverErr, _ := handshake(t, masterConfig, replicaConfig); serverErr == nil {
+		t.Fatalf("expected the master to reject a replica certificate signed by an untrusted authority")
+	}
+}
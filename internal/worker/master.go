@@ -2,6 +2,10 @@ package worker
 
 import (
 	"fmt"
+	"strconv"
+	"sync"
+
+	"memodb/internal/resp"
 
 	"github.com/google/uuid"
 )
@@ -18,17 +22,38 @@ func InitMasterWorker(worker *WorkerType, workerHost, workerPort string) (bool,
 	return true, nil
 }
 
-func PropagateCommand(buffer []byte) {
+// propagatedDb is the database selected in the replication stream, -1 when the next command has to select it
+var propagatedDb = -1
+var propagateMutex sync.Mutex
+
+// PropagateCommand sends a write command executed in database db to every replica, preceded by a SELECT when the
+// replication stream is on another database.
+func PropagateCommand(db int, buffer []byte) {
 	if worker.Role != "master" {
 		return
 	}
+	propagateMutex.Lock()
+	defer propagateMutex.Unlock()
+
+	if db != propagatedDb {
+		selectCommand, err := resp.SerializeResp(resp.NewBulkStringArray("SELECT", strconv.Itoa(db)))
+		if err != nil {
+			// without the SELECT the replicas would apply the command to the database of the previous one
+			fmt.Printf("Error serializing the SELECT propagated to the slaves: %s\n", err.Error())
+			return
+		}
+		buffer = append([]byte(selectCommand), buffer...)
+		propagatedDb = db
+	}
 
-	for _, slave := range  worker.Slaves {
-		go func(s Slave) {
-            _, err := s.connection.Write(buffer)
-            if err != nil {
-                fmt.Printf("Error writing to slave %s: %v\n", s.port, err)
-            }
-        }(slave)
+	for _, slave := range worker.Slaves {
+		if slave.send == nil {
+			// the replica did not ask for the dataset yet
+			continue
+		}
+		// a replica exceeding its output buffer limit is disconnected and removed once its connection is closed
+		if err := slave.send(buffer); err != nil {
+			fmt.Printf("Error writing to slave %s: %v\n", slave.port, err)
+		}
 	}
 }
\ No newline at end of file
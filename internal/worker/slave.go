@@ -1,11 +1,15 @@
 package worker
 
 import (
+	"crypto/tls"
 	"fmt"
+	"io"
 	"net"
 	"strings"
 
+	"memodb/internal/config"
 	"memodb/internal/resp"
+	"memodb/internal/store"
 	"memodb/internal/tcp"
 
 	"github.com/google/uuid"
@@ -33,13 +37,27 @@ func InitSlaveWorker(worker *WorkerType, workerHost, workerPort, masterHost, mas
 }
 
 func masterHandshake(masterHost, masterPort, workerPort string) (bool, error) {
-	conn, err := net.Dial("tcp", masterHost + ":" + masterPort)
+	var conn net.Conn
+	var err error
+	if config.GetBool("tls-replication") {
+		tlsConfig, configErr := tcp.ClientTLSConfig(masterHost)
+		if configErr != nil {
+			return false, configErr
+		}
+		conn, err = tls.Dial("tcp", net.JoinHostPort(masterHost, masterPort), tlsConfig)
+	} else {
+		conn, err = net.Dial("tcp", net.JoinHostPort(masterHost, masterPort))
+	}
 
 	if err != nil {
 		return false, err
 	}
 
-	pingHandshakeSuccess, err := pingHandshake(conn)
+	// the same reader is used for the whole lifetime of the connection as it may have buffered
+	// commands propagated by the master right after the handshake
+	respReader := resp.NewReader(conn)
+
+	pingHandshakeSuccess, err := pingHandshake(conn, respReader)
 	if !pingHandshakeSuccess || err != nil {
 		if err == nil {
 			return false, fmt.Errorf("error occurred while performing PING handshake with master")
@@ -47,21 +65,36 @@ func masterHandshake(masterHost, masterPort, workerPort string) (bool, error) {
 		return false, err
 	}
 
-	replConfigHandshakeSuccess, err := replConfigHandshake(conn, fmt.Sprintf("listening-port %s", workerPort))
+	if masterAuth := config.Get("masterauth"); masterAuth != "" {
+		if err := authHandshake(conn, respReader, config.Get("masteruser"), masterAuth); err != nil {
+			return false, err
+		}
+	}
+
+	replConfigHandshakeSuccess, err := replConfigHandshake(conn, respReader, fmt.Sprintf("listening-port %s", workerPort))
 	if !replConfigHandshakeSuccess || err != nil {
 		if err == nil {
 			return false, fmt.Errorf("error occurred while performing REPLCONFIG handshake with master")
 		}
 		return false, err
 	}
-	replConfigHandshakeSuccess, err = replConfigHandshake(conn, "capa psync2")
+	if announceIp := config.Get("replica-announce-ip"); announceIp != "" {
+		replConfigHandshakeSuccess, err = replConfigHandshake(conn, respReader, fmt.Sprintf("ip-address %s", announceIp))
+		if !replConfigHandshakeSuccess || err != nil {
+			if err == nil {
+				return false, fmt.Errorf("error occurred while performing REPLCONF handshake with master")
+			}
+			return false, err
+		}
+	}
+	replConfigHandshakeSuccess, err = replConfigHandshake(conn, respReader, "capa psync2")
 	if !replConfigHandshakeSuccess || err != nil {
 		if err == nil {
 			return false, fmt.Errorf("error occurred while performing REPLCONF handshake with master")
 		}
 		return false, err
 	}
-	psyncHandshakeSuccess, err := psyncHandshake(conn)
+	psyncHandshakeSuccess, err := psyncHandshake(conn, respReader)
 	if !psyncHandshakeSuccess || err != nil {
 		if err == nil {
 			return false, fmt.Errorf("error occurred while performing PSYNC handshake with master")
@@ -71,13 +104,14 @@ func masterHandshake(masterHost, masterPort, workerPort string) (bool, error) {
 
 	tcp.Connections = append(tcp.Connections, tcp.TCPConnection{
 		Connection: conn,
+		Reader: respReader,
 		Initialized: false,
 	})
 
 	return true, nil
 }
 
-func pingHandshake(conn net.Conn) (bool, error) {
+func pingHandshake(conn net.Conn, respReader *resp.Reader) (bool, error) {
 	pingCommand := resp.RespType{
 			DataType: resp.Array,
 			Array: []*resp.RespType{{
@@ -92,26 +126,46 @@ func pingHandshake(conn net.Conn) (bool, error) {
 		return false, err
 	}
 
-	responseBytes := make([]byte, 1024)
-	_, err = conn.Read(responseBytes)
+	deserializedPingCommandResp, err := respReader.ReadResp()
 	if err != nil {
 		return false, err
 	}
 
-	deserializedPingCommandResp, err := resp.DeserializeResp(responseBytes)
-	
-	if err != nil {
-		return false, err
-	}
-
-	if deserializedPingCommandResp.String != "PONG" {
+	// a master requiring a password replies NOAUTH, which still tells it is alive
+	isNoAuth := deserializedPingCommandResp.DataType == resp.Error && strings.HasPrefix(deserializedPingCommandResp.String, "NOAUTH")
+	if deserializedPingCommandResp.String != "PONG" && !isNoAuth {
 		return false, fmt.Errorf("error in receiving ping response from master")
 	}
 
 	return true, nil
 }
 
-func replConfigHandshake(conn net.Conn, command string) (bool, error) {
+// authHandshake authenticates the connection to the master with masterauth, as the ACL user masteruser when it is set
+// or else as the default user.
+func authHandshake(conn net.Conn, respReader *resp.Reader, username, password string) error {
+	authCommand := []string{"AUTH", password}
+	if username != "" {
+		authCommand = []string{"AUTH", username, password}
+	}
+	authCommandSerialized, _ := resp.SerializeResp(resp.NewBulkStringArray(authCommand...))
+	if _, err := conn.Write([]byte(authCommandSerialized)); err != nil {
+		return err
+	}
+
+	authResp, err := respReader.ReadResp()
+	if err != nil {
+		return err
+	}
+	if authResp.DataType == resp.Error {
+		return fmt.Errorf("master refused the authentication: %s", authResp.String)
+	}
+	if authResp.String != "OK" {
+		return fmt.Errorf("error in receiving auth response from master")
+	}
+	return nil
+}
+
+func replConfigHandshake(conn net.Conn, respReader *resp.Reader, command string) (bool, error) {
 	respArray := []*resp.RespType{{
 					DataType: resp.BulkString,
 					String: "REPLCONF",
@@ -132,18 +186,14 @@ func replConfigHandshake(conn net.Conn, command string) (bool, error) {
 		return false, err
 	}
 
-	responseBytes := make([]byte, 1024)
-	_, err = conn.Read(responseBytes)
+	deserializedReplConfigCommandResp, err := respReader.ReadResp()
 	if err != nil {
 		return false, err
 	}
 
-	deserializedReplConfigCommandResp, err := resp.DeserializeResp(responseBytes)
-	
-	if err != nil {
-		return false, err
+	if deserializedReplConfigCommandResp.DataType == resp.Error {
+		return false, fmt.Errorf("master replied to REPLCONF %s with an error: %s", command, deserializedReplConfigCommandResp.String)
 	}
-
 	if deserializedReplConfigCommandResp.String != "OK" {
 		return false, fmt.Errorf("error in receiving ping response from master")
 	}
@@ -151,7 +201,7 @@ func replConfigHandshake(conn net.Conn, command string) (bool, error) {
 	return true, nil
 }
 
-func psyncHandshake(conn net.Conn) (bool, error) {
+func psyncHandshake(conn net.Conn, respReader *resp.Reader) (bool, error) {
 	psyncCommand := resp.RespType{
 			DataType: resp.Array,
 			Array: []*resp.RespType{{
@@ -171,21 +221,26 @@ func psyncHandshake(conn net.Conn) (bool, error) {
 		return false, err
 	}
 
-	responseBytes := make([]byte, 1024)
-	_, err = conn.Read(responseBytes)
+	deserializedPsyncCommandResp, err := respReader.ReadResp()
 	if err != nil {
 		return false, err
 	}
 
-	// deserializedReplConfigCommandResp, err := resp.DeserializeResp(responseBytes)
-	
-	// if err != nil {
-	// 	return false, err
-	// }
+	if deserializedPsyncCommandResp.DataType != resp.String || !strings.HasPrefix(deserializedPsyncCommandResp.String, "FULLRESYNC") {
+		return false, fmt.Errorf("error in receiving psync response from master")
+	}
 
-	// if deserializedReplConfigCommandResp.String != "OK" {
-	// 	return false, fmt.Errorf("error in receiving ping response from master")
-	// }
+	payload, err := respReader.BulkPayload()
+	if err != nil {
+		return false, err
+	}
+	if err := store.LoadRdb(payload); err != nil {
+		return false, fmt.Errorf("error in loading the rdb snapshot sent by master: %s", err.Error())
+	}
+	// whatever follows the checksum is not part of the dump
+	if _, err := io.Copy(io.Discard, payload); err != nil {
+		return false, err
+	}
 
 	return true, nil
 }
\ No newline at end of file
@@ -1,12 +1,14 @@
 package worker
 
 import (
-	"memodb/internal/store"
 	"net"
 )
 type Slave struct {
+	ip string
 	port string
 	connection net.Conn
+	// send queues commands to replicate to the replica, it is nil until the replica asked for the dataset with PSYNC
+	send func(buffer []byte) error
 }
 type WorkerType struct {
 	Id string
@@ -36,8 +38,6 @@ func InitWorker(replica bool, workerHost, workerPort, masterHost, masterPort str
 			return "", err
 		}
 	}
-	store.SetStore("internal/worker/role", worker.Role)
-	store.SetStore("internal/worker/id", worker.Id)
 	return worker.Id, nil
 }
 
@@ -50,9 +50,69 @@ func UpdateSlaveDetailsForMaster(clientCon net.Conn, port string) bool {
 		return false
 	}
 
+	// the address the replica connected from, unless it announces another one with REPLCONF ip-address
+	ip, _, _ := net.SplitHostPort(clientCon.RemoteAddr().String())
+
+	propagateMutex.Lock()
+	defer propagateMutex.Unlock()
 	worker.Slaves = append(worker.Slaves, Slave{
+		ip: ip,
 		port: port,
 		connection: clientCon,
 	})
 	return true
-}
\ No newline at end of file
+}
+// UpdateSlaveAddress records the IP address a replica announced with REPLCONF ip-address, replicas behind NAT or in
+// containers are not reachable at the address they connected from.
+func UpdateSlaveAddress(clientCon net.Conn, ip string) bool {
+	propagateMutex.Lock()
+	defer propagateMutex.Unlock()
+	for idx := range worker.Slaves {
+		if worker.Slaves[idx].connection == clientCon {
+			worker.Slaves[idx].ip = ip
+			return true
+		}
+	}
+	return false
+}
+
+// RemoveSlave forgets a replica whose connection is closed, commands are no longer propagated to it.
+func RemoveSlave(clientCon net.Conn) bool {
+	propagateMutex.Lock()
+	defer propagateMutex.Unlock()
+	for idx := range worker.Slaves {
+		if worker.Slaves[idx].connection == clientCon {
+			worker.Slaves = append(worker.Slaves[:idx], worker.Slaves[idx + 1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+// StartStreaming starts replicating commands to a replica which asked for the dataset with PSYNC, send queues them to
+// the replica without waiting for it to read them.
+func StartStreaming(clientCon net.Conn, send func(buffer []byte) error) bool {
+	propagateMutex.Lock()
+	defer propagateMutex.Unlock()
+	for idx := range worker.Slaves {
+		if worker.Slaves[idx].connection == clientCon {
+			worker.Slaves[idx].send = send
+			// the stream of a new replica starts on database 0
+			propagatedDb = -1
+			return true
+		}
+	}
+	return false
+}
+
+// ConnectedSlaves returns the replicas connected to this master.
+func ConnectedSlaves() []Slave {
+	propagateMutex.Lock()
+	defer propagateMutex.Unlock()
+	return append([]Slave{}, worker.Slaves...)
+}
+
+// Address returns the IP address and the listening port of a replica.
+func (s Slave) Address() (string, string) {
+	return s.ip, s.port
+}
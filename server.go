@@ -1,95 +1,218 @@
 package main
 
 import (
+	"crypto/tls"
+	"errors"
 	"flag"
 	"fmt"
+	"io"
 	"net"
 	"os"
+	"os/signal"
+	"runtime/debug"
+	"strconv"
 	"strings"
+	"syscall"
 	"time"
 
+	"memodb/internal/acl"
+	"memodb/internal/aof"
+	"memodb/internal/client"
 	"memodb/internal/commands"
+	"memodb/internal/config"
+	"memodb/internal/resp"
 	"memodb/internal/store"
 	"memodb/internal/tcp"
 	"memodb/internal/worker"
 )
 
-// handleConnection function handles an incoming client TCP connection requests by acknowledging it with a response.
-func handleConnection(clientConn net.Conn, persist bool) {
+// handleConnection function handles an incoming client TCP connection by reading every (possibly pipelined) command
+// from it and responding to each one of them in order.
+func handleConnection(clientConn net.Conn, respReader *resp.Reader, persist bool) {
 	defer clientConn.Close()
+	defer func() {
+		// a bug while serving one client must not take the whole server down
+		if r := recover(); r != nil {
+			fmt.Printf("Recovered from panic while serving client %s: %v\n%s", clientConn.RemoteAddr(), r, debug.Stack())
+		}
+	}()
+
+	if tlsConn, isTLS := clientConn.(*tls.Conn); isTLS {
+		// a failed handshake is only worth a log line, the client can not read a reply
+		tlsConn.SetDeadline(time.Now().Add(10 * time.Second))
+		if err := tlsConn.Handshake(); err != nil {
+			fmt.Printf("Error in TLS handshake with client %s: %s\n", clientConn.RemoteAddr(), err.Error())
+			return
+		}
+		tlsConn.SetDeadline(time.Time{})
+	}
+
+	c := client.NewClient(clientConn)
+	// the persistent connection is the one we keep with our master
+	c.IsMaster = persist
+	// like in Redis, connections accepted while no password is required do not have to authenticate later on
+	c.Authenticated = persist || !commands.PasswordRequired()
+	client.Register(c)
+	defer client.Unregister(c)
+	// a replica whose connection is closed no longer receives the commands to replicate
+	defer worker.RemoveSlave(clientConn)
 
-	startTimestamp := time.Now().Unix() // timestamp at which we started dealing with the connection
 	for {
-		var buffer []byte = make([]byte, 10240)
-		numBytes, err := clientConn.Read(buffer)
+		// the read fails once the client stays idle for longer than the timeout
+		c.SetIdleTimeout(time.Duration(config.GetInt("timeout")) * time.Second)
+		respMsg, err := respReader.ReadCommand()
 		if err != nil {
-			if err.Error() != "EOF" {
+			var netErr net.Error
+			if err != io.EOF && err != io.ErrUnexpectedEOF && !errors.As(err, &netErr) {
+				// the stream can not be parsed any further, let the client know why before closing the connection
+				commands.ReplyError(c, fmt.Errorf("Protocol error: %s", err.Error()))
+				c.Flush()
 				fmt.Println("Error reading data from client: ", err.Error())
 			}
+			break
 		}
 
-		// if there are some incoming messages in buffer
-		if numBytes > 0 {
-			startTimestamp = time.Now().Unix() // if client is sending some data we keep the connection alive
-			isSuccess, propagateCommand, err := commands.HandleCommand(clientConn, buffer[:numBytes])
-			if propagateCommand {
-				worker.PropagateCommand(buffer)
-			}
-			if !isSuccess || err != nil {
-				fmt.Println("Error while responding to client: ", err.Error())
-			}
+		_, err = commands.HandleCommand(c, respMsg)
+		if err == nil && (respReader.Buffered() == 0 || c.CloseAfterReply) {
+			// the replies to a batch of pipelined commands are sent together, once every command of the batch is read
+			err = c.Flush()
 		}
-
-		if (time.Now().Unix() - startTimestamp) >= 30 && !persist {
-			// if we haven't receieved any data for more than 30 seconds and we do not want to persist we close the connection
-			break;
+		if err != nil {
+			fmt.Println("Error while responding to client: ", err.Error())
+			break
+		}
+		if c.CloseAfterReply {
+			break
 		}
 	}
-	
 }
 
 func main() {
-	port := flag.String("port", "6379", "Port on which the Redis server runs")
-	dir := flag.String("dir", "", "Path where RDB backups are stored")
-	dbFileName := flag.String("dbfilename", "", "Name of the backup file")
-	replicaOf := flag.String("replicaof", "", "Host Port")
+	// every configuration parameter can be given on the command line
+	for _, param := range config.Params() {
+		flag.String(param.Name, param.Default, param.Description)
+	}
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "Usage: %s [/path/to/memodb.conf] [-parameter value ...]\n", os.Args[0])
+		flag.PrintDefaults()
+	}
+	// like redis-server, the config file comes first, but it is also accepted after the flags
+	configFile := ""
+	args := os.Args[1:]
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		configFile, args = args[0], args[1:]
+	}
+	flag.CommandLine.Parse(args)
+	if flag.NArg() == 1 && configFile == "" {
+		configFile = flag.Arg(0)
+	} else if flag.NArg() > 0 {
+		flag.Usage()
+		os.Exit(1)
+	}
 
-	flag.Parse()
+	// flags override the values of the config file
+	if configFile != "" {
+		if err := config.LoadFile(configFile); err != nil {
+			fmt.Printf("Invalid config file: %s\n", err.Error())
+			os.Exit(1)
+		}
+	}
+	flag.Visit(func(f *flag.Flag) {
+		if err := config.Load(f.Name, f.Value.String()); err != nil {
+			fmt.Printf("Invalid -%s flag: %s\n", f.Name, err.Error())
+			os.Exit(1)
+		}
+	})
 
-	// Reading RDB Dump
-	if (*dir != "") {
-		commands.ConfigSet("dir", *dir)
+	store.SetDatabaseCount(config.GetInt("databases"))
+	acl.SetLogMaxLen(config.GetInt("acllog-max-len"))
+	outputBufferLimits := config.Lookup("client-output-buffer-limit")
+	outputBufferLimits.Apply(outputBufferLimits.Value())
+	// the users of the ACL file, the default user included, win over requirepass
+	acl.SetDefaultPassword(config.Get("requirepass"))
+	if aclFile := config.Get("aclfile"); aclFile != "" {
+		if err := acl.LoadFile(aclFile); err != nil {
+			fmt.Printf("Invalid ACL file: %s\n", err.Error())
+			os.Exit(1)
+		}
 	}
-	if (*dbFileName != "") {
-		commands.ConfigSet("dbfilename", *dbFileName)
-		_, err := store.LoadRdbInStore(*dir, *dbFileName)
+	aof.SetFsyncPolicy(config.Get("appendfsync"))
+
+	if config.GetBool("appendonly") {
+		// Replaying the append only file, which is always at least as recent as the RDB dump
+		dirPath, fileName := commands.AofLocation()
+		replayClient := client.NewFakeClient()
+		numCommands, err := aof.Load(dirPath, fileName, store.LoadKey, func(command *resp.RespType) error {
+			isSuccess, err := commands.HandleCommand(replayClient, command)
+			if err == nil && !isSuccess {
+				err = fmt.Errorf("unknown command or wrong arguments")
+			}
+			return err
+		})
+		if (err != nil) {
+			fmt.Printf("error occured while loading append only file: %s\n", err.Error())
+			os.Exit(1)
+		}
+		fmt.Printf("Replayed %d commands from the append only file\n", numCommands)
+		if err := aof.Open(dirPath, fileName); err != nil {
+			fmt.Println(err.Error())
+			os.Exit(1)
+		}
+	} else {
+		// Reading RDB Dump
+		_, err := store.LoadRdbInStore(commands.RdbLocation())
 		if (err != nil) {
 			fmt.Printf("error occured while loadig rdb dump: %s\n", err.Error())
 		}
 	}
-	
+	store.ResetDirty()
+
+	// Scheduling RDB dumps
+	saveParams, _ := store.ParseSaveParams(config.Get("save"))
+	store.SetSaveParams(saveParams)
+	store.StartSaveScheduler(commands.RdbLocation)
 
-	tcpListener, err := net.Listen("tcp", fmt.Sprintf("127.0.0.1:%s", *port))
+	port := config.Get("port")
+	tlsPort := config.Get("tls-port")
+	var tlsConfig *tls.Config
+	if tlsPort != "0" {
+		var err error
+		tlsConfig, err = tcp.ServerTLSConfig()
+		if err != nil {
+			fmt.Printf("Invalid TLS configuration: %s\n", err.Error())
+			os.Exit(1)
+		}
+	}
+	// replicas using TLS are reached by their master on their TLS port
+	announcedPort := port
+	if config.GetBool("tls-replication") && tlsPort != "0" {
+		announcedPort = tlsPort
+	}
+	replicaOf := strings.Fields(config.Get("replicaof"))
+	listeners, err := listen(port, tlsPort, tlsConfig)
 	if err != nil {
-		fmt.Printf("Failed to bind to port %s", *port, )
+		fmt.Println(err.Error())
 		os.Exit(1)
 	} else {
 		masterHost := ""
 		masterPort := ""
 		
-		if *replicaOf != "" {
-			masterHost = strings.Split(*replicaOf, " ")[0]
-			masterPort = strings.Split(*replicaOf, " ")[1]
+		if len(replicaOf) == 2 {
+			masterHost = replicaOf[0]
+			masterPort = replicaOf[1]
 		}
-		workerId, err := worker.InitWorker(*replicaOf != "", "127.0.0.1", *port, masterHost, masterPort)
+		workerId, err := worker.InitWorker(len(replicaOf) == 2, advertisedHost(), announcedPort, masterHost, masterPort)
 		if (workerId == "" || err != nil) {
+			if err != nil {
+				fmt.Printf("Error connecting to master: %s\n", err.Error())
+			}
 			fmt.Println("Could not connect to master. Exiting...")
 			return
 		}
 		fmt.Println()
 		fmt.Println()
 		fmt.Println("****************************************")
-		fmt.Printf("* MemoDB server is running on port %s *\n", *port)
+		fmt.Printf("* MemoDB server is running on port %s *\n", port)
 		fmt.Println("****************************************")
 		fmt.Println()
 		fmt.Println()
@@ -99,20 +222,193 @@ func main() {
 	for _, conn := range tcp.Connections {
 		if !conn.Initialized {
 			conn.Initialized = true;
-			go handleConnection(conn.Connection, true)
+			go handleConnection(conn.Connection, conn.Reader, true)
 		}
 			
 	}
 
-	// new tcp connections
+	// closing the listeners stops accepting connections, the file of the unix socket is removed along with its listener
+	commands.OnShutdown(func() {
+		for _, listener := range listeners {
+			listener.Close()
+		}
+	})
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGINT, syscall.SIGTERM)
+	go handleSignals(signals)
+
+	// new connections, each listener accepts them in a separate thread
+	for _, listener := range listeners {
+		go acceptConnections(listener)
+	}
+	select {}
+}
+
+// handleSignals shuts the server down on SIGINT and SIGTERM like SHUTDOWN does, a second signal received while the
+// shutdown is in progress makes the server exit at once.
+func handleSignals(signals <-chan os.Signal) {
+	for received := range signals {
+		if commands.ShutdownInProgress() {
+			fmt.Println("You insist... exiting now.")
+			os.Exit(1)
+		}
+		fmt.Printf("Received %s, scheduling shutdown...\n", received)
+		go func(received os.Signal) {
+			if err := commands.ShutdownServer(commands.ShutdownOptions{}); err != nil {
+				fmt.Printf("%s received but errors trying to shut down the server, check the logs for more information\n", received)
+			}
+		}(received)
+	}
+}
+
+// acceptConnections accepts the connections of a listener, each connection is handled in a separate thread. Once
+// maxclients connections are open new ones are refused.
+func acceptConnections(listener net.Listener) {
+	var backoff time.Duration
 	for {
-		clientConn, err := tcpListener.Accept()
+		clientConn, err := listener.Accept()
 		if err != nil {
-			fmt.Println("Error accepting clientConn: ", err.Error())
+			if errors.Is(err, net.ErrClosed) {
+				return
+			}
+			// errors such as running out of file descriptors last a while, retrying right away would spin
+			backoff = backoff * 2
+			if backoff == 0 {
+				backoff = 5 * time.Millisecond
+			} else if backoff > time.Second {
+				backoff = time.Second
+			}
+			fmt.Printf("Error accepting connection: %s, retrying in %s\n", err.Error(), backoff)
+			time.Sleep(backoff)
+			continue
+		}
+		backoff = 0
+
+		if !commands.AdmitConnection() {
+			go rejectConnection(clientConn)
+			continue
+		}
+		commands.CountConnection()
+		setKeepAlive(clientConn)
+		go func() {
+			defer commands.ReleaseConnection()
+			handleConnection(clientConn, resp.NewReader(clientConn), false)
+		}()
+	}
+}
+
+// rejectConnection tells a client that its connection is refused because maxclients connections are open, then
+// closes the connection.
+func rejectConnection(clientConn net.Conn) {
+	defer clientConn.Close()
+	// the TLS handshake of TLS connections happens on the write
+	clientConn.SetDeadline(time.Now().Add(10 * time.Second))
+	clientConn.Write([]byte("-ERR max number of clients reached\r\n"))
+}
+
+// setKeepAlive enables TCP keepalive probes on a client connection every tcp-keepalive seconds, or disables them when
+// it is 0, so the connections of clients that disappeared without closing them are eventually closed.
+func setKeepAlive(clientConn net.Conn) {
+	if tlsConn, isTLS := clientConn.(*tls.Conn); isTLS {
+		clientConn = tlsConn.NetConn()
+	}
+	tcpConn, isTCP := clientConn.(*net.TCPConn)
+	if !isTCP {
+		return
+	}
+	period := config.GetInt("tcp-keepalive")
+	tcpConn.SetKeepAlive(period > 0)
+	if period > 0 {
+		tcpConn.SetKeepAlivePeriod(time.Duration(period) * time.Second)
+	}
+}
+
+// listen opens a TCP listener on port and a TLS listener on tlsPort for every bind address, and a listener on the unix
+// socket when one is configured. Failing to listen on an address prefixed by '-' is not an error, the address is
+// skipped. Like in Redis, port 0 disables TCP and tlsPort 0 disables TLS.
+func listen(port, tlsPort string, tlsConfig *tls.Config) ([]net.Listener, error) {
+	listeners := []net.Listener{}
+	fail := func(err error) ([]net.Listener, error) {
+		for _, listener := range listeners {
+			listener.Close()
 		}
+		return nil, err
+	}
 
-		// each connection is handled in a separate thread
-		go handleConnection(clientConn, false)
+	endpoints := []struct {
+		port string
+		tlsConfig *tls.Config
+	}{{port, nil}, {tlsPort, tlsConfig}}
+	for _, endpoint := range endpoints {
+		if endpoint.port == "0" {
+			continue
+		}
+		for _, address := range strings.Fields(config.Get("bind")) {
+			optional := strings.HasPrefix(address, "-")
+			address = strings.TrimPrefix(address, "-")
+			// IPv6 listeners only accept IPv6 connections, so * and ::* can be bound together
+			network, host := "tcp4", address
+			switch {
+				case address == "*": {
+					host = "0.0.0.0"
+				}
+				case address == "::*": {
+					network, host = "tcp6", "::"
+				}
+				case strings.Contains(address, ":"): {
+					network = "tcp6"
+				}
+			}
+
+			listener, err := net.Listen(network, net.JoinHostPort(host, endpoint.port))
+			if err != nil {
+				if optional {
+					fmt.Printf("Skipping optional bind address %s: %s\n", address, err.Error())
+					continue
+				}
+				return fail(fmt.Errorf("Failed to bind to %s: %s", net.JoinHostPort(host, endpoint.port), err.Error()))
+			}
+			if endpoint.tlsConfig != nil {
+				listener = tls.NewListener(listener, endpoint.tlsConfig)
+			}
+			listeners = append(listeners, listener)
+		}
+	}
+
+	if path := config.Get("unixsocket"); path != "" {
+		// a socket left behind by a previous run would make listening fail
+		if info, err := os.Stat(path); err == nil && info.Mode() & os.ModeSocket != 0 {
+			os.Remove(path)
+		}
+		listener, err := net.Listen("unix", path)
+		if err != nil {
+			return fail(fmt.Errorf("Failed to open unix socket %s: %s", path, err.Error()))
+		}
+		listeners = append(listeners, listener)
+		if perm, _ := strconv.ParseUint(config.Get("unixsocketperm"), 8, 32); perm != 0 {
+			if err := os.Chmod(path, os.FileMode(perm)); err != nil {
+				return fail(fmt.Errorf("Failed to set the permissions of unix socket %s: %s", path, err.Error()))
+			}
+		}
+	}
+
+	if len(listeners) == 0 {
+		return nil, fmt.Errorf("Failed to listen, no bind address nor unix socket is available")
+	}
+	return listeners, nil
+}
+
+// advertisedHost returns the address the server tells others to reach it at: the announced IP when one is configured,
+// else the first bind address that is not a wildcard.
+func advertisedHost() string {
+	if announceIp := config.Get("replica-announce-ip"); announceIp != "" {
+		return announceIp
+	}
+	for _, address := range strings.Fields(config.Get("bind")) {
+		address = strings.TrimPrefix(address, "-")
+		if address != "*" && address != "::*" {
+			return address
+		}
 	}
-	
+	return "127.0.0.1"
 }